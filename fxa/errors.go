@@ -0,0 +1,188 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// Returned for calls that need the tokens from a successful login.
+	ErrNotLoggedIn = errors.New("Not logged in")
+	// Returned for responses with a body larger than the maximum response
+	// size of the client.
+	ErrResponseTooLarge = errors.New("Response body too large")
+	// Returned when the uid or a token in a login response is not hex of
+	// the expected length. The error wraps it with the name of the field.
+	ErrBadToken = errors.New("Malformed token in response")
+	// Returned when the key bundle is not hex of the expected length.
+	ErrBadKeyBundle = errors.New("Malformed key bundle in response")
+	// Returned when the MAC of the key bundle does not match. The bundle was
+	// modified, or it was not encrypted for the token that fetched it.
+	ErrKeyBundleMAC = errors.New("Key bundle MAC mismatch")
+)
+
+// Maximum length of the body snippet in an HTTPError.
+const maxHTTPErrorBody = 512
+
+// An error response that does not carry a JSON error from the auth server,
+// like an HTML page from a proxy or an empty body.
+type HTTPError struct {
+	StatusCode  int
+	ContentType string
+	// The start of the body, cut off after 512 bytes.
+	Body string
+}
+
+func newHTTPError(res *http.Response, body []byte) *HTTPError {
+	snippet := string(body)
+	if len(snippet) > maxHTTPErrorBody {
+		snippet = strings.ToValidUTF8(snippet[:maxHTTPErrorBody], "") + "..."
+	}
+	return &HTTPError{
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        snippet,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("Unexpected HTTP status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("Unexpected HTTP status %d (%s): %s", e.StatusCode, e.ContentType, e.Body)
+}
+
+// An errno from the auth server. It identifies the kind of error more
+// precisely than the HTTP status. Errno values can be used with errors.Is to
+// check for a specific *ErrorResponse.
+type Errno int
+
+// The errnos documented in the Firefox Accounts auth server API.
+const (
+	ErrnoAccountExists           Errno = 101
+	ErrnoUnknownAccount          Errno = 102
+	ErrnoIncorrectPassword       Errno = 103
+	ErrnoUnverifiedAccount       Errno = 104
+	ErrnoInvalidVerificationCode Errno = 105
+	ErrnoInvalidJSON             Errno = 106
+	ErrnoInvalidParameter        Errno = 107
+	ErrnoMissingParameter        Errno = 108
+	ErrnoInvalidSignature        Errno = 109
+	ErrnoInvalidToken            Errno = 110
+	ErrnoInvalidTimestamp        Errno = 111
+	ErrnoMissingContentLength    Errno = 112
+	ErrnoRequestTooLarge         Errno = 113
+	ErrnoThrottled               Errno = 114
+	ErrnoInvalidNonce            Errno = 115
+	ErrnoEndpointNotSupported    Errno = 116
+	ErrnoIncorrectEmailCase      Errno = 120
+	ErrnoUnknownDevice           Errno = 123
+	ErrnoRequestBlocked          Errno = 125
+	ErrnoAccountResetRequired    Errno = 126
+	ErrnoInvalidUnblockCode      Errno = 127
+	ErrnoUnverifiedSession       Errno = 138
+	ErrnoServiceUnavailable      Errno = 201
+	ErrnoFeatureDisabled         Errno = 202
+	ErrnoUnknown                 Errno = 999
+)
+
+var errnoDescriptions = map[Errno]string{
+	ErrnoAccountExists:           "Account already exists",
+	ErrnoUnknownAccount:          "Unknown account",
+	ErrnoIncorrectPassword:       "Incorrect password",
+	ErrnoUnverifiedAccount:       "Unverified account",
+	ErrnoInvalidVerificationCode: "Invalid verification code",
+	ErrnoInvalidJSON:             "Invalid JSON in request body",
+	ErrnoInvalidParameter:        "Invalid parameter in request body",
+	ErrnoMissingParameter:        "Missing parameter in request body",
+	ErrnoInvalidSignature:        "Invalid request signature",
+	ErrnoInvalidToken:            "Invalid authentication token in request signature",
+	ErrnoInvalidTimestamp:        "Invalid timestamp in request signature",
+	ErrnoMissingContentLength:    "Missing content-length header",
+	ErrnoRequestTooLarge:         "Request body too large",
+	ErrnoThrottled:               "Client has sent too many requests",
+	ErrnoInvalidNonce:            "Invalid nonce in request signature",
+	ErrnoEndpointNotSupported:    "This endpoint is no longer supported",
+	ErrnoIncorrectEmailCase:      "Incorrect email case",
+	ErrnoUnknownDevice:           "Unknown device",
+	ErrnoRequestBlocked:          "The request was blocked for security reasons",
+	ErrnoAccountResetRequired:    "Account must be reset",
+	ErrnoInvalidUnblockCode:      "Invalid unblock code",
+	ErrnoUnverifiedSession:       "Unverified session",
+	ErrnoServiceUnavailable:      "Service unavailable",
+	ErrnoFeatureDisabled:         "Feature disabled",
+	ErrnoUnknown:                 "Unspecified error",
+}
+
+func (e Errno) Error() string {
+	if description, ok := errnoDescriptions[e]; ok {
+		return fmt.Sprintf("%s (errno %d)", description, int(e))
+	}
+	return fmt.Sprintf("errno %d", int(e))
+}
+
+// An error response from the auth server.
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Errno   Errno  `json:"errno"`
+	Err     string `json:"error"`
+	Message string `json:"message"`
+	Info    string `json:"info"`
+	// Seconds to wait before retrying a throttled request.
+	RetryAfter int `json:"retryAfter,omitempty"`
+	// Server time in seconds since the epoch, sent with invalid timestamp errors.
+	ServerTime int64 `json:"serverTime,omitempty"`
+	// How an unverified account or session can be verified, like "email".
+	VerificationMethod string `json:"verificationMethod,omitempty"`
+}
+
+// Returns the message of the error with its errno, or the short error string
+// if there is no message.
+func (e *ErrorResponse) Error() string {
+	if e.Message == "" {
+		return e.Err
+	}
+	return fmt.Sprintf("%s (errno %d)", e.Message, int(e.Errno))
+}
+
+// Reports whether the target is the Errno of the response, so that
+// errors.Is(err, ErrnoThrottled) works.
+func (e *ErrorResponse) Is(target error) bool {
+	errno, ok := target.(Errno)
+	return ok && errno == e.Errno
+}
+
+// Reports whether the call was rejected because the client sent too many
+// requests. The RetryAfter field of the *ErrorResponse says how long to wait.
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrnoThrottled)
+}
+
+// Reports whether the call was rejected because the account or the session
+// is not verified yet.
+func IsUnverified(err error) bool {
+	return errors.Is(err, ErrnoUnverifiedAccount) || errors.Is(err, ErrnoUnverifiedSession)
+}
+
+// Reports whether the token that the call was signed with is not valid, for
+// example because it expired or the session was destroyed. A new login is
+// needed.
+func IsInvalidToken(err error) bool {
+	return errors.Is(err, ErrnoInvalidToken)
+}
+
+// Reports whether the login failed because of an incorrect password.
+func IsIncorrectPassword(err error) bool {
+	return errors.Is(err, ErrnoIncorrectPassword)
+}
+
+// Reports whether the call failed because the account does not exist.
+func IsUnknownAccount(err error) bool {
+	return errors.Is(err, ErrnoUnknownAccount)
+}
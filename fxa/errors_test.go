@@ -0,0 +1,151 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_ErrorResponse_JSON(t *testing.T) {
+	body := `{"code":400,"errno":104,"error":"Bad Request","message":"Unverified account","info":"https://example.com","verificationMethod":"email","retryAfter":30,"serverTime":1353832234}`
+
+	errorResponse := &ErrorResponse{}
+	if err := json.Unmarshal([]byte(body), errorResponse); err != nil {
+		t.Fatal("Cannot decode error response: ", err)
+	}
+	if errorResponse.Errno != ErrnoUnverifiedAccount || errorResponse.VerificationMethod != "email" || errorResponse.RetryAfter != 30 || errorResponse.ServerTime != 1353832234 {
+		t.Errorf("Unexpected error response: %#v", errorResponse)
+	}
+	if errorResponse.Error() != "Unverified account (errno 104)" {
+		t.Error("Unexpected error string: ", errorResponse.Error())
+	}
+}
+
+func Test_ErrorResponse_Is(t *testing.T) {
+	var err error = fmt.Errorf("call failed: %w", &ErrorResponse{Code: 429, Errno: ErrnoThrottled, Err: "Too Many Requests"})
+
+	if !errors.Is(err, ErrnoThrottled) || errors.Is(err, ErrnoInvalidToken) {
+		t.Error("errors.Is does not match the errno")
+	}
+
+	var errorResponse *ErrorResponse
+	if !errors.As(err, &errorResponse) || errorResponse.Code != 429 {
+		t.Error("errors.As does not find the error response")
+	}
+
+	if !IsThrottled(err) || IsUnverified(err) || IsInvalidToken(err) {
+		t.Error("Unexpected classification")
+	}
+}
+
+func Test_ErrorHelpers(t *testing.T) {
+	tests := []struct {
+		err   error
+		check func(error) bool
+	}{
+		{&ErrorResponse{Errno: ErrnoThrottled}, IsThrottled},
+		{&ErrorResponse{Errno: ErrnoUnverifiedAccount}, IsUnverified},
+		{&ErrorResponse{Errno: ErrnoUnverifiedSession}, IsUnverified},
+		{&ErrorResponse{Errno: ErrnoInvalidToken}, IsInvalidToken},
+		{&ErrorResponse{Errno: ErrnoIncorrectPassword}, IsIncorrectPassword},
+		{&ErrorResponse{Errno: ErrnoUnknownAccount}, IsUnknownAccount},
+	}
+	for _, test := range tests {
+		if !test.check(test.err) {
+			t.Error("Helper does not match errno ", test.err.(*ErrorResponse).Errno)
+		}
+		if test.check(errors.New("some error")) || test.check(nil) {
+			t.Error("Helper matches an unrelated error")
+		}
+	}
+}
+
+func Test_Errno_Error(t *testing.T) {
+	if ErrnoIncorrectPassword.Error() != "Incorrect password (errno 103)" {
+		t.Error("Unexpected error string: ", ErrnoIncorrectPassword.Error())
+	}
+	if Errno(12345).Error() != "errno 12345" {
+		t.Error("Unexpected error string: ", Errno(12345).Error())
+	}
+}
+
+func Test_IsIncorrectPasswordWithTestServer(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "wrongpassword")
+	if err := client.Login(); !IsIncorrectPassword(err) {
+		t.Error("Expected an incorrect password error. Got ", err)
+	}
+
+	client, _ = NewClientWithOptions("unknown@sateh.com", "secret1234", WithBaseURL(ts.URL()))
+	if err := client.Login(); !IsUnknownAccount(err) {
+		t.Error("Expected an unknown account error. Got ", err)
+	}
+}
+
+func Test_HTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html>Bad Gateway</html>", strings.Repeat(" ", 1000))
+	}))
+	defer ts.Close()
+
+	client, _ := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(ts.URL))
+	err := client.Login()
+
+	var httpError *HTTPError
+	if !errors.As(err, &httpError) {
+		t.Fatalf("Expected an *HTTPError. Got %#v", err)
+	}
+	if httpError.StatusCode != http.StatusBadGateway || httpError.ContentType != "text/html" {
+		t.Error("Unexpected status or content type: ", httpError)
+	}
+	if !strings.HasPrefix(httpError.Body, "<html>Bad Gateway</html>") || len(httpError.Body) != maxHTTPErrorBody+len("...") {
+		t.Error("Body snippet not truncated: ", len(httpError.Body))
+	}
+}
+
+func Test_HTTPError_EmptyBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client, _ := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(ts.URL))
+	err := client.Login()
+
+	var httpError *HTTPError
+	if !errors.As(err, &httpError) || httpError.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected an *HTTPError with status 503. Got %#v", err)
+	}
+	if err.Error() != "Unexpected HTTP status 503" {
+		t.Error("Unexpected error message: ", err)
+	}
+}
+
+func Test_ResponseTooLarge(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"uid":"%s"}`, strings.Repeat("0", 100))
+	}))
+	defer ts.Close()
+
+	client, _ := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(ts.URL), WithMaxResponseSize(64))
+	if err := client.Login(); err != ErrResponseTooLarge {
+		t.Error("Expected ErrResponseTooLarge. Got ", err)
+	}
+
+	if _, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithMaxResponseSize(0)); err == nil {
+		t.Error("Expected an error for a zero max response size")
+	}
+}
@@ -6,41 +6,48 @@ package fxa
 
 import (
 	"bytes"
+	"context"
 	"crypto/dsa"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
-	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/st3fan/gofxa/hawk"
 )
 
-// Structure that maintains the state of a Firefox Accounts Client.
+// Structure that maintains the state of a Firefox Accounts Client. A client
+// is safe for concurrent use by multiple goroutines.
 type Client struct {
-	email         string
-	password      string
-	authPW        []byte
-	unwrapBKey    []byte
-	uid           string // After /account/login
+	email       string
+	password    string
+	authPW      []byte
+	unwrapBKey  []byte
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+	verifyHawk  bool // Verify Server-Authorization of signed calls
+	logger      *slog.Logger
+	observer    Observer
+	// Limit on the size of response bodies, or zero for the default.
+	maxResponseSize int64
+
+	mu            sync.RWMutex // Guards the fields below
+	uid           string       // After /account/login
 	sessionToken  []byte
 	keyFetchToken []byte
-	KeyA          []byte
-	KeyB          []byte
-}
-
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Errno   int    `json:"errno"`
-	Err     string `json:"error"`
-	Message string `json:"message"`
-	Info    string `json:"info"`
-}
-
-func (e *ErrorResponse) Error() string {
-	return e.Err
+	keyA          []byte
+	keyB          []byte
+	clockOffset   time.Duration // Server time minus local time
 }
 
 type loginRequest struct {
@@ -77,6 +84,12 @@ type signCertificateResponse struct {
 
 // Create a new client with the specified email and password.
 func NewClient(email, password string) (*Client, error) {
+	return NewClientWithOptions(email, password)
+}
+
+// Create a new client with the specified email, password and options. Without
+// options the client talks to the production Firefox Accounts service.
+func NewClientWithOptions(email, password string, options ...ClientOption) (*Client, error) {
 	authPW, err := deriveAuthPWFromQuickStretchedPassword(quickStretchPassword(email, password))
 	if err != nil {
 		return nil, err
@@ -87,132 +100,340 @@ func NewClient(email, password string) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{
+	c := &Client{
 		email:      email,
 		password:   password,
 		authPW:     authPW,
 		unwrapBKey: unwrapBKey,
-	}, nil
-}
+		baseURL:    ProductionServer,
+		httpClient: http.DefaultClient,
+	}
 
-// Login to the Firefox Accounts service.
-func (c *Client) Login() error {
-	request := loginRequest{
-		Email:  c.email,
-		AuthPW: hex.EncodeToString(c.authPW),
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
 	}
-	encodedRequest, err := json.Marshal(request)
-	if err != nil {
-		return err
+
+	return c, nil
+}
+
+// Returns the absolute URL for the given API path on the configured server.
+func (c *Client) endpoint(path string) string {
+	return c.baseURL + path
+}
+
+// An API call to the auth server. Calls that carry a token are signed with
+// Hawk credentials derived from it. Idempotent calls can safely be retried
+// after a server or network error.
+type apiRequest struct {
+	method     string
+	path       string
+	body       []byte
+	token      []byte
+	tokenName  string
+	idempotent bool
+}
+
+// Returns the path of the call without the query string, which identifies the
+// endpoint in logs and metrics.
+func (ar *apiRequest) endpoint() string {
+	if i := strings.IndexByte(ar.path, '?'); i != -1 {
+		return ar.path[:i]
 	}
+	return ar.path
+}
 
-	u, err := url.Parse("https://api.accounts.firefox.com/v1/account/login?keys=true")
-	if err != nil {
-		return err
+// Build the HTTP request for an API call, signing it if needed. Returns the
+// Hawk signer that the request was signed with, or nil.
+func (c *Client) newHTTPRequest(ctx context.Context, ar *apiRequest) (*http.Request, *hawk.Signer, error) {
+	var body io.Reader
+	if ar.body != nil {
+		body = bytes.NewReader(ar.body)
 	}
 
-	res, err := http.Post(u.String(), "application/json", bytes.NewBuffer(encodedRequest))
+	req, err := http.NewRequestWithContext(ctx, ar.method, c.endpoint(ar.path), body)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if ar.body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return err
+	if ar.token != nil {
+		requestCredentials, err := newRequestCredentials(ar.token, ar.tokenName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var payload io.Reader
+		if ar.body != nil {
+			payload = bytes.NewReader(ar.body)
+		}
+
+		signer := hawk.NewSigner(hawk.NewCredentials(hex.EncodeToString(requestCredentials.TokenId), requestCredentials.RequestHMACKey))
+		c.mu.RLock()
+		signer.Offset = c.clockOffset
+		c.mu.RUnlock()
+		signer.RequireResponseHash = true
+		if err := signer.AuthorizeRequest(req, payload, ""); err != nil {
+			return nil, nil, err
+		}
+		return req, signer, nil
 	}
 
-	if res.StatusCode != http.StatusOK {
-		errorResponse := &ErrorResponse{}
-		if err := json.Unmarshal(body, &errorResponse); err != nil {
+	return req, nil, nil
+}
+
+// Execute an API call and decode the JSON response into response. Non-200
+// responses are returned as an *ErrorResponse. Failed calls are retried
+// according to the retry policy of the client. A signed call rejected because
+// of clock skew is retried once with a corrected timestamp. If the call fails
+// because ctx was cancelled or its deadline expired then ctx.Err() is
+// returned. The observer of the client, if any, is notified around the call.
+func (c *Client) call(ctx context.Context, ar *apiRequest, response interface{}) error {
+	if c.observer == nil {
+		return c.callWithRetries(ctx, ar, response, &CallInfo{})
+	}
+
+	info := &CallInfo{Endpoint: ar.endpoint()}
+	ctx = c.observer.BeginCall(ctx, info.Endpoint)
+	start := time.Now()
+	err := c.callWithRetries(ctx, ar, response, info)
+	info.Duration, info.Err = time.Since(start), err
+	c.observer.EndCall(ctx, *info)
+
+	return err
+}
+
+// The retry loop of call. Records the outcome of the last attempt and the
+// number of retries in info.
+func (c *Client) callWithRetries(ctx context.Context, ar *apiRequest, response interface{}, info *CallInfo) error {
+	attempt, skewCorrected := 1, false
+	for {
+		res, body, err := c.roundTrip(ctx, ar)
+		info.record(res, err)
+		if err == nil {
+			return json.Unmarshal(body, response)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if ar.token != nil && !skewCorrected && c.correctClockSkew(err) {
+			skewCorrected = true
+			info.Retries++
+			continue
+		}
+
+		delay, retry := c.retryPolicy.delay(attempt, ar, res, err)
+		if !retry {
 			return err
-		} else {
-			return errorResponse
 		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		attempt++
+		info.Retries++
 	}
+}
 
-	response := &loginResponse{}
-	if err = json.Unmarshal(body, response); err != nil {
-		return err
+// Track the clock offset to the server from the Timestamp header that the
+// auth server adds to its responses, or else from the Date header.
+func (c *Client) updateClockOffset(res *http.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if timestamp, err := strconv.ParseInt(res.Header.Get("Timestamp"), 10, 64); err == nil {
+		c.clockOffset = time.Unix(timestamp, 0).Sub(time.Now()).Round(time.Second)
+	} else if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+		c.clockOffset = date.Sub(time.Now()).Round(time.Second)
 	}
+}
 
-	c.uid = response.Uid
-	c.sessionToken, _ = hex.DecodeString(response.SessionToken)
-	c.keyFetchToken, _ = hex.DecodeString(response.KeyFetchToken)
+// Correct the clock offset from the server time in an invalid timestamp
+// error. Returns true if the error was an invalid timestamp error.
+func (c *Client) correctClockSkew(err error) bool {
+	errorResponse, ok := err.(*ErrorResponse)
+	if !ok || errorResponse.Errno != ErrnoInvalidTimestamp || errorResponse.ServerTime == 0 {
+		return false
+	}
+	c.mu.Lock()
+	c.clockOffset = time.Unix(errorResponse.ServerTime, 0).Sub(time.Now()).Round(time.Second)
+	c.mu.Unlock()
+	return true
+}
 
-	return nil
+// Make a single attempt at an API call. Returns the response and its body, or
+// an error. The response is also returned with an *ErrorResponse for non-200
+// responses, so that the caller can inspect the status and headers.
+func (c *Client) roundTrip(ctx context.Context, ar *apiRequest) (*http.Response, []byte, error) {
+	req, signer, err := c.newHTTPRequest(ctx, ar)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	res, body, err := c.send(ctx, req, signer)
+	c.logRoundTrip(ctx, req, signer, res, err, time.Since(start))
+
+	return res, body, err
 }
 
-// Fetch encryption keys from the Firefox Accounts service.
-func (c *Client) FetchKeys() error {
-	u, err := url.Parse("https://api.accounts.firefox.com/v1/account/keys")
+// Send a request built by newHTTPRequest and read the response.
+func (c *Client) send(ctx context.Context, req *http.Request, signer *hawk.Signer) (*http.Response, []byte, error) {
+	res, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
 	}
+	defer res.Body.Close()
 
-	client := &http.Client{}
+	c.updateClockOffset(res)
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	body, err := c.readBody(res)
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return res, nil, newResponseError(res, body, err)
+	}
+	if err == ErrResponseTooLarge {
+		return res, nil, err
+	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	requestCredentials, err := newRequestCredentials(c.keyFetchToken, "keyFetchToken")
+	if c.verifyHawk && signer != nil {
+		if err := signer.ValidateResponse(req, res, body); err != nil {
+			return res, nil, err
+		}
+	}
+
+	return res, body, nil
+}
+
+// Read the response body, up to the maximum response size. A larger body
+// fails with ErrResponseTooLarge. Returns what was read, also on errors.
+func (c *Client) readBody(res *http.Response) ([]byte, error) {
+	limit := c.maxResponseSize
+	if limit == 0 {
+		limit = DefaultMaxResponseSize
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, limit+1))
 	if err != nil {
-		return err
+		return body, err
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], ErrResponseTooLarge
 	}
+	return body, nil
+}
 
-	hawkCredentials := NewHawkCredentials(hex.EncodeToString(requestCredentials.TokenId), requestCredentials.RequestHMACKey)
-	if err := hawkCredentials.AuthorizeRequest(req, nil, ""); err != nil {
-		return err
+// Returns the error for a non-200 response: the *ErrorResponse in its body,
+// or an *HTTPError if the body is not a complete JSON error response.
+func newResponseError(res *http.Response, body []byte, err error) error {
+	if err == nil {
+		errorResponse := &ErrorResponse{}
+		if err := json.Unmarshal(body, errorResponse); err == nil && (errorResponse.Errno != 0 || errorResponse.Code != 0) {
+			return errorResponse
+		}
 	}
+	return newHTTPError(res, body)
+}
+
+// Login to the Firefox Accounts service.
+func (c *Client) Login() error {
+	return c.LoginContext(context.Background())
+}
 
-	res, err := client.Do(req)
+// Login to the Firefox Accounts service. The context controls cancellation
+// and deadline of the underlying HTTP request.
+func (c *Client) LoginContext(ctx context.Context) error {
+	ar, err := c.loginAPIRequest()
 	if err != nil {
 		return err
 	}
-	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
+	response := &loginResponse{}
+	if err := c.call(ctx, ar, response); err != nil {
 		return err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		errorResponse := &ErrorResponse{}
-		if err := json.Unmarshal(body, &errorResponse); err != nil {
-			return err
-		} else {
-			return errorResponse
-		}
+	if _, ok := decodeHex(response.Uid, uidSize); !ok {
+		return fmt.Errorf("%w: uid", ErrBadToken)
+	}
+	sessionToken, ok := decodeHex(response.SessionToken, tokenSize)
+	if !ok {
+		return fmt.Errorf("%w: sessionToken", ErrBadToken)
+	}
+	keyFetchToken, ok := decodeHex(response.KeyFetchToken, tokenSize)
+	if !ok {
+		return fmt.Errorf("%w: keyFetchToken", ErrBadToken)
+	}
+
+	c.mu.Lock()
+	c.uid = response.Uid
+	c.sessionToken = sessionToken
+	c.keyFetchToken = keyFetchToken
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Fetch encryption keys from the Firefox Accounts service.
+func (c *Client) FetchKeys() error {
+	return c.FetchKeysContext(context.Background())
+}
+
+// Fetch encryption keys from the Firefox Accounts service. The context
+// controls cancellation and deadline of the underlying HTTP request.
+func (c *Client) FetchKeysContext(ctx context.Context) error {
+	ar, err := c.fetchKeysAPIRequest()
+	if err != nil {
+		return err
 	}
 
 	response := &keysResponse{}
-	if err = json.Unmarshal(body, response); err != nil {
+	if err := c.call(ctx, ar, response); err != nil {
 		return err
 	}
 
-	accountKeys, err := newAccountKeys(requestCredentials.RequestKey)
+	requestCredentials, err := newRequestCredentials(ar.token, "keyFetchToken")
 	if err != nil {
 		return err
 	}
 
-	//
-
-	bundle, err := hex.DecodeString(response.Bundle)
+	accountKeys, err := newAccountKeys(requestCredentials.RequestKey)
 	if err != nil {
 		return err
 	}
 
+	// The bundle is the encrypted kA and wrap(kB), followed by their MAC
+
+	bundle, ok := decodeHex(response.Bundle, keyBundleSize)
+	if !ok {
+		return ErrBadKeyBundle
+	}
+
 	ct := bundle[0:64]
 	respMAC := bundle[64:96]
 
 	mac := hmac.New(sha256.New, accountKeys.HMACKey)
 	mac.Write(ct)
-	respMAC2 := mac.Sum(nil)
 
-	if !bytes.Equal(respMAC, respMAC2) {
-		return errors.New("Response MAC failure something bad")
+	if !hmac.Equal(respMAC, mac.Sum(nil)) {
+		return ErrKeyBundleMAC
 	}
 
 	// Finally derive kA and kB
@@ -221,87 +442,93 @@ func (c *Client) FetchKeys() error {
 	for i := 0; i < 64; i++ {
 		t1[i] = ct[i] ^ accountKeys.XORKey[i]
 	}
-	c.KeyA = t1[0:32]
-
 	wrapKB := t1[32:64]
 
 	var t2 [32]byte
 	for i := 0; i < 32; i++ {
 		t2[i] = c.unwrapBKey[i] ^ wrapKB[i]
 	}
-	c.KeyB = t2[:]
+
+	c.mu.Lock()
+	c.keyA = t1[0:32]
+	c.keyB = t2[:]
+	c.mu.Unlock()
 
 	return nil
 }
 
-// Sign a certificate with the given DSA key. Returns an encoded certificate.
-func (c *Client) SignCertificate(key *dsa.PrivateKey) (string, error) {
-	u, err := url.Parse("https://api.accounts.firefox.com/v1/certificate/sign")
-	if err != nil {
-		return "", err
-	}
+// Returns a copy of kA, or nil if the keys were not fetched.
+func (c *Client) KeyA() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyBytes(c.keyA)
+}
 
-	request := signCertificateRequest{
-		PublicKey: publicKey{
-			Algorithm: "DS",
-			Y:         fmt.Sprintf("%x", key.PublicKey.Y),
-			P:         fmt.Sprintf("%x", key.PublicKey.Parameters.P),
-			Q:         fmt.Sprintf("%x", key.PublicKey.Parameters.Q),
-			G:         fmt.Sprintf("%x", key.PublicKey.Parameters.G),
-		},
-		Duration: 86400000,
-	}
-	encodedRequest, err := json.Marshal(request)
-	if err != nil {
-		return "", err
-	}
+// Returns a copy of kB, or nil if the keys were not fetched.
+func (c *Client) KeyB() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyBytes(c.keyB)
+}
 
-	client := &http.Client{}
+// Returns the uid of the account, or an empty string before login.
+func (c *Client) UID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.uid
+}
 
-	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(encodedRequest))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
+// Sizes in bytes of the hex encoded values returned by the auth server.
+const (
+	uidSize       = 16
+	tokenSize     = 32
+	keyBundleSize = 96 // Ciphertext of kA and wrap(kB), followed by a MAC
+)
 
-	requestCredentials, err := newRequestCredentials(c.sessionToken, "sessionToken")
+// Decode a hex string that must hold exactly size bytes.
+func decodeHex(s string, size int) ([]byte, bool) {
+	if len(s) != 2*size {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
 	if err != nil {
-		return "", err
+		return nil, false
 	}
+	return b, true
+}
 
-	hawkCredentials := NewHawkCredentials(hex.EncodeToString(requestCredentials.TokenId), requestCredentials.RequestHMACKey)
-	if err := hawkCredentials.AuthorizeRequest(req, bytes.NewReader(encodedRequest), ""); err != nil {
-		return "", err
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
 	}
+	return append([]byte{}, b...)
+}
 
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close()
+// Sign a certificate with the given DSA key. Returns an encoded certificate.
+func (c *Client) SignCertificate(key *dsa.PrivateKey) (string, error) {
+	return c.SignCertificateContext(context.Background(), key)
+}
 
-	body, err := ioutil.ReadAll(res.Body)
+// Sign a certificate with the given DSA key. Returns an encoded certificate.
+// The context controls cancellation and deadline of the underlying HTTP
+// request.
+func (c *Client) SignCertificateContext(ctx context.Context, key *dsa.PrivateKey) (string, error) {
+	ar, err := c.signCertificateAPIRequest(key)
 	if err != nil {
 		return "", err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		errorResponse := &ErrorResponse{}
-		if err := json.Unmarshal(body, &errorResponse); err != nil {
-			return "", err
-		} else {
-			return "", errorResponse
-		}
-	}
-
 	response := &signCertificateResponse{}
-	if err = json.Unmarshal(body, response); err != nil {
+	if err := c.call(ctx, ar, response); err != nil {
 		return "", err
 	}
 
 	return response.Certificate, nil
 }
 
+// Describe the client without its password, tokens and keys.
 func (c *Client) String() string {
-	return fmt.Sprintf("<fxa.Client email=%s password=%s uid=%s sessionToken=%v keyFetchToken=%v>", c.email, c.password, c.uid, c.sessionToken, c.keyFetchToken)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return fmt.Sprintf("<fxa.Client email=%s uid=%s sessionToken=%s keyFetchToken=%s>", c.email, c.uid, redact(c.sessionToken), redact(c.keyFetchToken))
 }
@@ -5,12 +5,219 @@
 package fxa
 
 import (
+	"bytes"
+	"context"
 	"crypto/dsa"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/st3fan/gofxa/hawk"
 )
 
+// A fake auth server that implements just enough of the Firefox Accounts API
+// to exercise the client without talking to the real service.
+type testServer struct {
+	*httptest.Server
+	email         string
+	authPW        []byte
+	unwrapBKey    []byte
+	sessionToken  []byte
+	keyFetchToken []byte
+	keyA          []byte
+	keyB          []byte
+	clockSkew     time.Duration              // Added to the local time to get the server time
+	clockHeaders  bool                       // Send Date and Timestamp headers
+	signResponses bool                       // Send Server-Authorization with signed calls
+	tamper        bool                       // Modify response bodies after signing them
+	mangleBundle  func(bundle []byte) []byte // Modify key bundles before encoding them
+	keysRequests  int32
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newTestServer(t *testing.T, email, password string) *testServer {
+	authPW, err := deriveAuthPWFromQuickStretchedPassword(quickStretchPassword(email, password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapBKey, err := deriveUnwrapBKeyFromQuickStretchedPassword(quickStretchPassword(email, password))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &testServer{
+		email:         email,
+		authPW:        authPW,
+		unwrapBKey:    unwrapBKey,
+		sessionToken:  randomBytes(t, 32),
+		keyFetchToken: randomBytes(t, 32),
+		keyA:          randomBytes(t, 32),
+		keyB:          randomBytes(t, 32),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/account/login", ts.handleLogin)
+	mux.HandleFunc("/v1/account/keys", ts.handleKeys)
+	mux.HandleFunc("/v1/certificate/sign", ts.handleSignCertificate)
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ts.clockHeaders {
+			now := time.Now().Add(ts.clockSkew)
+			w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+			w.Header().Set("Timestamp", strconv.FormatInt(now.Unix(), 10))
+		} else {
+			w.Header()["Date"] = nil
+		}
+		mux.ServeHTTP(w, r)
+	}))
+
+	return ts
+}
+
+func (ts *testServer) URL() string {
+	return ts.Server.URL + "/v1"
+}
+
+func writeTestResponse(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Write a response to a Hawk signed call, with a Server-Authorization header if
+// the server signs responses.
+func (ts *testServer) writeHawkResponse(w http.ResponseWriter, r *http.Request, token []byte, tokenName string, v interface{}) {
+	body, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+
+	if ts.signResponses {
+		requestCredentials, _ := newRequestCredentials(token, tokenName)
+		authorization, _ := hawk.ParseAuthorization(r.Header.Get("Authorization"))
+		payloadHash := sha256.Sum256([]byte("hawk.1.payload\napplication/json\n" + string(body) + "\n"))
+		hash := base64.StdEncoding.EncodeToString(payloadHash[:])
+		host, port, _ := net.SplitHostPort(r.Host)
+		normalized := fmt.Sprintf("hawk.1.response\n%d\n%s\n%s\n%s\n%s\n%s\n%s\n\n", authorization.TS, authorization.Nonce, r.Method, r.URL.RequestURI(), host, port, hash)
+		mac := hmac.New(sha256.New, requestCredentials.RequestHMACKey)
+		mac.Write([]byte(normalized))
+		w.Header().Set("Server-Authorization", fmt.Sprintf(`Hawk mac="%s", hash="%s"`, base64.StdEncoding.EncodeToString(mac.Sum(nil)), hash))
+	}
+
+	if ts.tamper {
+		body = bytes.Replace(body, []byte(`":"`), []byte(`":"00`), 1)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (ts *testServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	request := loginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeTestResponse(w, http.StatusBadRequest, &ErrorResponse{Code: 400, Errno: 106, Err: "Bad Request", Message: "Invalid JSON in request body"})
+		return
+	}
+	if request.Email != ts.email {
+		writeTestResponse(w, http.StatusBadRequest, &ErrorResponse{Code: 400, Errno: 102, Err: "Bad Request", Message: "Unknown account"})
+		return
+	}
+	if request.AuthPW != hex.EncodeToString(ts.authPW) {
+		writeTestResponse(w, http.StatusBadRequest, &ErrorResponse{Code: 400, Errno: 103, Err: "Bad Request", Message: "Incorrect password", Info: "https://github.com/mozilla/fxa-auth-server/blob/master/docs/api.md#response-format"})
+		return
+	}
+	writeTestResponse(w, http.StatusOK, &loginResponse{
+		Uid:           "4c352927cd4f4a4aa03d7d1893d950b8",
+		SessionToken:  hex.EncodeToString(ts.sessionToken),
+		KeyFetchToken: hex.EncodeToString(ts.keyFetchToken),
+	})
+}
+
+// Check that the request has a Hawk timestamp within a minute of the server
+// clock. Writes an error response and returns false if it does not.
+func (ts *testServer) checkAuthorization(w http.ResponseWriter, r *http.Request) bool {
+	authorization, err := hawk.ParseAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		writeTestResponse(w, http.StatusUnauthorized, &ErrorResponse{Code: 401, Errno: 109, Err: "Unauthorized", Message: "Invalid request signature"})
+		return false
+	}
+	now := time.Now().Add(ts.clockSkew)
+	if skew := now.Sub(time.Unix(authorization.TS, 0)); skew > time.Minute || skew < -time.Minute {
+		writeTestResponse(w, http.StatusUnauthorized, &ErrorResponse{Code: 401, Errno: 111, Err: "Unauthorized", Message: "Invalid timestamp in request signature", ServerTime: now.Unix()})
+		return false
+	}
+	return true
+}
+
+func (ts *testServer) handleKeys(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&ts.keysRequests, 1)
+	if !ts.checkAuthorization(w, r) {
+		return
+	}
+
+	requestCredentials, _ := newRequestCredentials(ts.keyFetchToken, "keyFetchToken")
+	accountKeys, _ := newAccountKeys(requestCredentials.RequestKey)
+
+	plaintext := append(append([]byte{}, ts.keyA...), ts.keyB...)
+	for i := 0; i < 32; i++ {
+		plaintext[32+i] ^= ts.unwrapBKey[i]
+	}
+	ct := make([]byte, 64)
+	for i := 0; i < 64; i++ {
+		ct[i] = plaintext[i] ^ accountKeys.XORKey[i]
+	}
+
+	mac := hmac.New(sha256.New, accountKeys.HMACKey)
+	mac.Write(ct)
+
+	bundle := append(ct, mac.Sum(nil)...)
+	if ts.mangleBundle != nil {
+		bundle = ts.mangleBundle(bundle)
+	}
+
+	ts.writeHawkResponse(w, r, ts.keyFetchToken, "keyFetchToken", &keysResponse{Bundle: hex.EncodeToString(bundle)})
+}
+
+func (ts *testServer) handleSignCertificate(w http.ResponseWriter, r *http.Request) {
+	if !ts.checkAuthorization(w, r) {
+		return
+	}
+	request := signCertificateRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PublicKey.Algorithm != "DS" {
+		writeTestResponse(w, http.StatusBadRequest, &ErrorResponse{Code: 400, Errno: 107, Err: "Bad Request", Message: "Invalid parameter in request body"})
+		return
+	}
+	ts.writeHawkResponse(w, r, ts.sessionToken, "sessionToken", &signCertificateResponse{Certificate: "eyJhbGciOiJSUzI1NiJ9.fake.certificate"})
+}
+
+// Returns a client that talks to the test server.
+func newTestClient(t *testing.T, ts *testServer, password string, options ...ClientOption) *Client {
+	client, err := NewClientWithOptions(ts.email, password, append([]ClientOption{WithBaseURL(ts.URL())}, options...)...)
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+	return client
+}
+
 func Test_NewClient(t *testing.T) {
 	client, err := NewClient("gofxa@sateh.com", "secret1234")
 	if client == nil || err != nil {
@@ -81,3 +288,340 @@ func Test_BadLogin(t *testing.T) {
 		t.Error("Incomplete error received")
 	}
 }
+
+func Test_LoginWithTestServer(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+
+	if !bytes.Equal(client.KeyA(), ts.keyA) || !bytes.Equal(client.KeyB(), ts.keyB) {
+		t.Error("Did not get expected keys")
+	}
+
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	if cert, err := client.SignCertificate(key); err != nil || cert == "" {
+		t.Error("Cannot sign certificate: ", err)
+	}
+}
+
+func Test_BadLoginWithTestServer(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "wrongpassword")
+
+	err := client.Login()
+	errorResponse, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("Expected an fxa.ErrorResponse. Got %#v", err)
+	}
+
+	if errorResponse.Code != 400 || errorResponse.Errno != 103 {
+		t.Error("Unexpected error received")
+	}
+}
+
+func Test_LoginContext_DeadlineExceeded(t *testing.T) {
+	stalled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-stalled:
+		}
+	}))
+	defer server.Close()
+	defer close(stalled)
+
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(server.URL+"/v1"))
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.LoginContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded. Got %#v", err)
+	}
+}
+
+func Test_FetchKeysContext_Cancelled(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := client.FetchKeysContext(ctx); err != context.Canceled {
+		t.Errorf("Expected context.Canceled. Got %#v", err)
+	}
+
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	if _, err := client.SignCertificateContext(ctx, key); err != context.Canceled {
+		t.Errorf("Expected context.Canceled. Got %#v", err)
+	}
+}
+
+func Test_ClockSkew_InvalidTimestamp(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+	ts.clockSkew = time.Hour
+
+	client := newTestClient(t, ts, "secret1234")
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+	if ts.keysRequests != 2 {
+		t.Errorf("Expected 2 requests. Got %d", ts.keysRequests)
+	}
+
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	// The corrected offset is used for the following requests
+	if _, err := client.SignCertificate(key); err != nil {
+		t.Error("Cannot sign certificate: ", err)
+	}
+}
+
+func Test_ClockSkew_TimestampHeader(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+	ts.clockSkew = -time.Hour
+	ts.clockHeaders = true
+
+	client := newTestClient(t, ts, "secret1234")
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if client.clockOffset > -59*time.Minute || client.clockOffset < -61*time.Minute {
+		t.Error("Unexpected clock offset: ", client.clockOffset)
+	}
+
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+	if ts.keysRequests != 1 {
+		t.Errorf("Expected 1 request. Got %d", ts.keysRequests)
+	}
+}
+
+func Test_ClockSkew_RetriedOnce(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		writeTestResponse(w, http.StatusUnauthorized, &ErrorResponse{Code: 401, Errno: 111, Err: "Unauthorized", ServerTime: time.Now().Unix()})
+	}))
+	defer server.Close()
+
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(server.URL+"/v1"))
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+	client.keyFetchToken = make([]byte, 32)
+
+	if err := client.FetchKeys(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests. Got %d", requests)
+	}
+}
+
+func Test_ResponseVerification(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+	ts.signResponses = true
+
+	client := newTestClient(t, ts, "secret1234", WithResponseVerification())
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+	if !bytes.Equal(client.KeyA(), ts.keyA) || !bytes.Equal(client.KeyB(), ts.keyB) {
+		t.Error("Did not get expected keys")
+	}
+}
+
+func Test_ResponseVerification_Tampered(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+	ts.signResponses = true
+	ts.tamper = true
+
+	client := newTestClient(t, ts, "secret1234", WithResponseVerification())
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != hawk.ErrBadPayloadHash {
+		t.Errorf("Expected hawk.ErrBadPayloadHash. Got %#v", err)
+	}
+}
+
+func Test_ResponseVerification_Unsigned(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234", WithResponseVerification())
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != hawk.ErrMissingHeader {
+		t.Errorf("Expected hawk.ErrMissingHeader. Got %#v", err)
+	}
+}
+
+func Test_KeysAreCopied(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+	if client.KeyA() != nil || client.KeyB() != nil || client.UID() != "" {
+		t.Error("Expected no keys and uid before login")
+	}
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+
+	client.KeyA()[0] ^= 0xff
+	client.KeyB()[0] ^= 0xff
+	if !bytes.Equal(client.KeyA(), ts.keyA) || !bytes.Equal(client.KeyB(), ts.keyB) {
+		t.Error("Keys of the client were modified through a returned copy")
+	}
+	if client.UID() != "4c352927cd4f4a4aa03d7d1893d950b8" {
+		t.Error("Unexpected uid: ", client.UID())
+	}
+}
+
+// Run with -race to check that the client can be shared between goroutines.
+func Test_ConcurrentUse(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+	ts.clockHeaders = true
+
+	client := newTestClient(t, ts, "secret1234", WithObserver(NewMetricsCollector()))
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			errs <- client.Login()
+		}()
+		go func() {
+			defer wg.Done()
+			if err := client.FetchKeys(); err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(client.KeyA(), ts.keyA) || !bytes.Equal(client.KeyB(), ts.keyB) {
+				errs <- fmt.Errorf("unexpected keys")
+			}
+			_ = client.String()
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := client.SignCertificate(key)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error("Concurrent call failed: ", err)
+		}
+	}
+}
+
+func Test_Login_BadTokens(t *testing.T) {
+	responses := map[string]*loginResponse{
+		"uid":           {Uid: "not-hex", SessionToken: strings.Repeat("00", 32), KeyFetchToken: strings.Repeat("00", 32)},
+		"sessionToken":  {Uid: strings.Repeat("00", 16), SessionToken: strings.Repeat("00", 31), KeyFetchToken: strings.Repeat("00", 32)},
+		"keyFetchToken": {Uid: strings.Repeat("00", 16), SessionToken: strings.Repeat("00", 32), KeyFetchToken: strings.Repeat("zz", 32)},
+	}
+
+	for field, response := range responses {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeTestResponse(w, http.StatusOK, response)
+		}))
+
+		client, _ := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(server.URL))
+		err := client.Login()
+		if !errors.Is(err, ErrBadToken) || !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected ErrBadToken for %s. Got %v", field, err)
+		}
+		if client.UID() != "" {
+			t.Error("Expected no uid after a bad login response")
+		}
+
+		server.Close()
+	}
+}
+
+func Test_FetchKeys_BadKeyBundle(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	ts.mangleBundle = func(bundle []byte) []byte { return bundle[0:64] }
+	if err := client.FetchKeys(); err != ErrBadKeyBundle {
+		t.Error("Expected ErrBadKeyBundle for a short bundle. Got ", err)
+	}
+
+	ts.mangleBundle = func(bundle []byte) []byte { bundle[0] ^= 1; return bundle }
+	if err := client.FetchKeys(); err != ErrKeyBundleMAC {
+		t.Error("Expected ErrKeyBundleMAC for a modified bundle. Got ", err)
+	}
+
+	if client.KeyA() != nil || client.KeyB() != nil {
+		t.Error("Expected no keys after a bad key bundle")
+	}
+}
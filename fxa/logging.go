@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/st3fan/gofxa/hawk"
+)
+
+// Placeholder for secrets in logs and in the output of Client.String.
+const redacted = "[redacted]"
+
+// Returns the placeholder for a secret, or an empty string if it is not set.
+func redact(secret []byte) string {
+	if secret == nil {
+		return ""
+	}
+	return redacted
+}
+
+// Log every HTTP request to the auth server at debug level, with its method,
+// path, status, errno, latency and Hawk id. Request and response bodies are
+// never logged, because they carry authPW, tokens, key bundles and
+// certificates.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) error {
+		if logger == nil {
+			return errors.New("fxa: nil slog.Logger")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// Log a single attempt at an API call.
+func (c *Client) logRoundTrip(ctx context.Context, req *http.Request, signer *hawk.Signer, res *http.Response, err error, latency time.Duration) {
+	if c.logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("latency", latency),
+	}
+	if signer != nil {
+		attrs = append(attrs, slog.String("hawk_id", signer.Credentials.ID()))
+	}
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+	}
+
+	var errorResponse *ErrorResponse
+	if errors.As(err, &errorResponse) {
+		attrs = append(attrs, slog.Int("errno", int(errorResponse.Errno)))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "fxa request", attrs...)
+}
+
+// Implements slog.LogValuer so that logging a client does not leak its
+// password, tokens or keys.
+func (c *Client) LogValue() slog.Value {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slog.GroupValue(
+		slog.String("email", c.email),
+		slog.String("uid", c.uid),
+		slog.String("sessionToken", redact(c.sessionToken)),
+		slog.String("keyFetchToken", redact(c.keyFetchToken)),
+	)
+}
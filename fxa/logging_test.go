@@ -0,0 +1,108 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestLogger(buffer *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func Test_WithLogger(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := newTestClient(t, ts, "secret1234", WithLogger(newTestLogger(&buffer)))
+
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+	if _, err := client.SignCertificate(key); err != nil {
+		t.Fatal("Cannot sign certificate: ", err)
+	}
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal("Cannot parse log record: ", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 log records. Got %d", len(records))
+	}
+
+	if records[0]["method"] != "POST" || records[0]["path"] != "/v1/account/login" || records[0]["status"] != float64(200) || records[0]["hawk_id"] != nil {
+		t.Error("Unexpected login record: ", records[0])
+	}
+	requestCredentials, _ := newRequestCredentials(ts.keyFetchToken, "keyFetchToken")
+	if records[1]["method"] != "GET" || records[1]["path"] != "/v1/account/keys" || records[1]["hawk_id"] != hex.EncodeToString(requestCredentials.TokenId) {
+		t.Error("Unexpected keys record: ", records[1])
+	}
+	if _, ok := records[2]["latency"]; !ok {
+		t.Error("Missing latency: ", records[2])
+	}
+
+	for _, secret := range []string{"secret1234", hex.EncodeToString(ts.authPW), hex.EncodeToString(ts.sessionToken), hex.EncodeToString(ts.keyFetchToken), hex.EncodeToString(ts.keyA), hex.EncodeToString(ts.keyB), "fake.certificate"} {
+		if strings.Contains(buffer.String(), secret) {
+			t.Error("Log contains a secret: ", secret)
+		}
+	}
+}
+
+func Test_WithLogger_Errno(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := newTestClient(t, ts, "wrongpassword", WithLogger(newTestLogger(&buffer)))
+	if err := client.Login(); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if !strings.Contains(buffer.String(), `"status":400,"errno":103`) {
+		t.Error("Unexpected log: ", buffer.String())
+	}
+}
+
+func Test_Client_Redaction(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	var buffer bytes.Buffer
+	newTestLogger(&buffer).Info("client", "client", client)
+
+	for _, s := range []string{client.String(), buffer.String()} {
+		if !strings.Contains(s, "gofxa@sateh.com") || !strings.Contains(s, redacted) {
+			t.Error("Unexpected description: ", s)
+		}
+		for _, secret := range []string{"secret1234", hex.EncodeToString(ts.sessionToken), hex.EncodeToString(ts.keyFetchToken)} {
+			if strings.Contains(s, secret) {
+				t.Error("Description contains a secret: ", s)
+			}
+		}
+	}
+}
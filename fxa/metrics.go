@@ -0,0 +1,159 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Upper bounds in seconds of the call duration histogram buckets.
+var metricsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricsCallKey struct {
+	endpoint string
+	status   int
+	errno    Errno
+}
+
+type metricsDuration struct {
+	buckets []uint64 // Cumulative counts for metricsDurationBuckets
+	sum     float64
+	count   uint64
+}
+
+// A MetricsCollector is an Observer that counts API calls, retries and call
+// durations per endpoint. It serves them in the Prometheus text format, so
+// that it can be mounted on a /metrics endpoint.
+type MetricsCollector struct {
+	mu        sync.Mutex
+	calls     map[metricsCallKey]uint64
+	retries   map[string]uint64
+	durations map[string]*metricsDuration
+}
+
+// Create an empty collector.
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		calls:     map[metricsCallKey]uint64{},
+		retries:   map[string]uint64{},
+		durations: map[string]*metricsDuration{},
+	}
+}
+
+// Implements Observer. The context is returned unchanged.
+func (m *MetricsCollector) BeginCall(ctx context.Context, endpoint string) context.Context {
+	return ctx
+}
+
+// Implements Observer. Counts the call and records its duration.
+func (m *MetricsCollector) EndCall(ctx context.Context, info CallInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls[metricsCallKey{info.Endpoint, info.Status, info.Errno}]++
+	m.retries[info.Endpoint] += uint64(info.Retries)
+
+	duration, ok := m.durations[info.Endpoint]
+	if !ok {
+		duration = &metricsDuration{buckets: make([]uint64, len(metricsDurationBuckets))}
+		m.durations[info.Endpoint] = duration
+	}
+	seconds := info.Duration.Seconds()
+	for i, bound := range metricsDurationBuckets {
+		if seconds <= bound {
+			duration.buckets[i]++
+		}
+	}
+	duration.sum += seconds
+	duration.count++
+}
+
+func formatMetricsFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Returns copies of the counters and histograms, so that they can be written
+// without holding the lock.
+func (m *MetricsCollector) snapshot() (map[metricsCallKey]uint64, map[string]uint64, map[string]metricsDuration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := make(map[metricsCallKey]uint64, len(m.calls))
+	for key, count := range m.calls {
+		calls[key] = count
+	}
+	retries := make(map[string]uint64, len(m.retries))
+	for endpoint, count := range m.retries {
+		retries[endpoint] = count
+	}
+	durations := make(map[string]metricsDuration, len(m.durations))
+	for endpoint, duration := range m.durations {
+		durations[endpoint] = metricsDuration{
+			buckets: append([]uint64{}, duration.buckets...),
+			sum:     duration.sum,
+			count:   duration.count,
+		}
+	}
+	return calls, retries, durations
+}
+
+// Write the metrics in the Prometheus text exposition format.
+func (m *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	calls, retries, durations := m.snapshot()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	keys := make([]metricsCallKey, 0, len(calls))
+	for key := range calls {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		if keys[i].status != keys[j].status {
+			return keys[i].status < keys[j].status
+		}
+		return keys[i].errno < keys[j].errno
+	})
+
+	endpoints := make([]string, 0, len(durations))
+	for endpoint := range durations {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintln(b, "# HELP fxa_calls_total Number of API calls to the auth server.")
+	fmt.Fprintln(b, "# TYPE fxa_calls_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(b, "fxa_calls_total{endpoint=%q,status=\"%d\",errno=\"%d\"} %d\n", key.endpoint, key.status, key.errno, calls[key])
+	}
+
+	fmt.Fprintln(b, "# HELP fxa_call_retries_total Number of retried attempts of API calls.")
+	fmt.Fprintln(b, "# TYPE fxa_call_retries_total counter")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(b, "fxa_call_retries_total{endpoint=%q} %d\n", endpoint, retries[endpoint])
+	}
+
+	fmt.Fprintln(b, "# HELP fxa_call_duration_seconds Duration of API calls, including retries.")
+	fmt.Fprintln(b, "# TYPE fxa_call_duration_seconds histogram")
+	for _, endpoint := range endpoints {
+		duration := durations[endpoint]
+		for i, bound := range metricsDurationBuckets {
+			fmt.Fprintf(b, "fxa_call_duration_seconds_bucket{endpoint=%q,le=\"%s\"} %d\n", endpoint, formatMetricsFloat(bound), duration.buckets[i])
+		}
+		fmt.Fprintf(b, "fxa_call_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", endpoint, duration.count)
+		fmt.Fprintf(b, "fxa_call_duration_seconds_sum{endpoint=%q} %s\n", endpoint, formatMetricsFloat(duration.sum))
+		fmt.Fprintf(b, "fxa_call_duration_seconds_count{endpoint=%q} %d\n", endpoint, duration.count)
+	}
+}
@@ -0,0 +1,104 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_MetricsCollector(t *testing.T) {
+	collector := NewMetricsCollector()
+	collector.EndCall(context.Background(), CallInfo{Endpoint: "/account/login", Duration: 20 * time.Millisecond, Status: 200})
+	collector.EndCall(context.Background(), CallInfo{Endpoint: "/account/login", Duration: 2 * time.Second, Status: 400, Errno: 103, Retries: 1})
+	collector.EndCall(context.Background(), CallInfo{Endpoint: "/account/keys", Duration: 30 * time.Millisecond, Status: 200, Retries: 2})
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+
+	for _, line := range []string{
+		"# TYPE fxa_calls_total counter",
+		`fxa_calls_total{endpoint="/account/keys",status="200",errno="0"} 1`,
+		`fxa_calls_total{endpoint="/account/login",status="200",errno="0"} 1`,
+		`fxa_calls_total{endpoint="/account/login",status="400",errno="103"} 1`,
+		`fxa_call_retries_total{endpoint="/account/keys"} 2`,
+		`fxa_call_retries_total{endpoint="/account/login"} 1`,
+		"# TYPE fxa_call_duration_seconds histogram",
+		`fxa_call_duration_seconds_bucket{endpoint="/account/login",le="0.025"} 1`,
+		`fxa_call_duration_seconds_bucket{endpoint="/account/login",le="2.5"} 2`,
+		`fxa_call_duration_seconds_bucket{endpoint="/account/login",le="+Inf"} 2`,
+		`fxa_call_duration_seconds_sum{endpoint="/account/login"} 2.02`,
+		`fxa_call_duration_seconds_count{endpoint="/account/keys"} 1`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Error("Missing line: ", line)
+		}
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") {
+		t.Error("Unexpected content type: ", recorder.Header().Get("Content-Type"))
+	}
+}
+
+// A ResponseWriter that blocks writes until it is released.
+type stalledResponseWriter struct {
+	header  http.Header
+	once    sync.Once
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *stalledResponseWriter) Header() http.Header { return w.header }
+func (w *stalledResponseWriter) WriteHeader(int)     {}
+
+func (w *stalledResponseWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return len(b), nil
+}
+
+func Test_MetricsCollector_StalledScraper(t *testing.T) {
+	collector := NewMetricsCollector()
+	collector.EndCall(context.Background(), CallInfo{Endpoint: "/account/login", Duration: 20 * time.Millisecond, Status: 200})
+
+	w := &stalledResponseWriter{header: http.Header{}, writing: make(chan struct{}), release: make(chan struct{})}
+	defer close(w.release)
+	go collector.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	<-w.writing
+
+	done := make(chan struct{})
+	go func() {
+		collector.EndCall(context.Background(), CallInfo{Endpoint: "/account/keys", Duration: 30 * time.Millisecond, Status: 200})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("EndCall blocked while metrics were being written")
+	}
+}
+
+func Test_MetricsCollector_Client(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	collector := NewMetricsCollector()
+	client := newTestClient(t, ts, "secret1234", WithObserver(collector))
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), `fxa_calls_total{endpoint="/account/login",status="200",errno="0"} 1`) {
+		t.Error("Unexpected metrics: ", recorder.Body.String())
+	}
+}
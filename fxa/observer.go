@@ -0,0 +1,62 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// The outcome of an API call, including all of its retries.
+type CallInfo struct {
+	// The path of the endpoint, like "/account/login".
+	Endpoint string
+	// Time spent on the call, including retries and the delays between them.
+	Duration time.Duration
+	// The HTTP status of the last attempt, or 0 if it got no response.
+	Status int
+	// The errno of the last attempt, or 0 if it did not fail with an
+	// *ErrorResponse.
+	Errno Errno
+	// The number of attempts after the first one.
+	Retries int
+	// The error that the call returned, or nil.
+	Err error
+}
+
+// Record the outcome of a single attempt.
+func (info *CallInfo) record(res *http.Response, err error) {
+	info.Status, info.Errno = 0, 0
+	if res != nil {
+		info.Status = res.StatusCode
+	}
+	var errorResponse *ErrorResponse
+	if errors.As(err, &errorResponse) {
+		info.Errno = errorResponse.Errno
+	}
+}
+
+// An Observer is notified around every API call that a client makes, for
+// metrics or tracing.
+type Observer interface {
+	// Called when a call starts. The returned context is used for the call
+	// and passed to EndCall, so that a tracer can attach a span to it.
+	BeginCall(ctx context.Context, endpoint string) context.Context
+	// Called when a call is done, after its last attempt.
+	EndCall(ctx context.Context, info CallInfo)
+}
+
+// Notify the observer around every API call.
+func WithObserver(observer Observer) ClientOption {
+	return func(c *Client) error {
+		if observer == nil {
+			return errors.New("fxa: nil Observer")
+		}
+		c.observer = observer
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+type testObserverKey struct{}
+
+// An observer that records the calls it sees.
+type testObserver struct {
+	begun []string
+	calls []CallInfo
+	spans []interface{}
+}
+
+func (o *testObserver) BeginCall(ctx context.Context, endpoint string) context.Context {
+	o.begun = append(o.begun, endpoint)
+	return context.WithValue(ctx, testObserverKey{}, endpoint)
+}
+
+func (o *testObserver) EndCall(ctx context.Context, info CallInfo) {
+	o.calls = append(o.calls, info)
+	o.spans = append(o.spans, ctx.Value(testObserverKey{}))
+}
+
+func Test_WithObserver(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	observer := &testObserver{}
+	client := newTestClient(t, ts, "secret1234", WithObserver(observer), WithRetryPolicy(testRetryPolicy))
+
+	failFirstRequests(ts, 2, http.StatusTooManyRequests, nil, `{"code":429,"errno":114,"error":"Too Many Requests","retryAfter":0}`)
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+
+	if len(observer.begun) != 2 || observer.begun[0] != "/account/login" || observer.begun[1] != "/account/keys" {
+		t.Fatal("Unexpected calls: ", observer.begun)
+	}
+
+	login := observer.calls[0]
+	if login.Endpoint != "/account/login" || login.Status != 200 || login.Errno != 0 || login.Retries != 2 || login.Err != nil || login.Duration <= 0 {
+		t.Errorf("Unexpected login call: %+v", login)
+	}
+	if keys := observer.calls[1]; keys.Endpoint != "/account/keys" || keys.Status != 200 || keys.Retries != 0 {
+		t.Errorf("Unexpected keys call: %+v", keys)
+	}
+	if observer.spans[0] != "/account/login" || observer.spans[1] != "/account/keys" {
+		t.Error("Context from BeginCall was not passed to EndCall: ", observer.spans)
+	}
+}
+
+func Test_WithObserver_Error(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	observer := &testObserver{}
+	client := newTestClient(t, ts, "wrongpassword", WithObserver(observer))
+	err := client.Login()
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if len(observer.calls) != 1 || observer.calls[0].Status != 400 || observer.calls[0].Errno != 103 || observer.calls[0].Err != err {
+		t.Errorf("Unexpected calls: %+v", observer.calls)
+	}
+}
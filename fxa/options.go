@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Base URLs of well known Firefox Accounts auth servers.
+const (
+	ProductionServer = "https://api.accounts.firefox.com/v1"
+	StageServer      = "https://api-accounts.stage.mozaws.net/v1"
+	LocalServer      = "http://127.0.0.1:9000/v1"
+)
+
+// A ClientOption configures a Client created with NewClientWithOptions.
+type ClientOption func(*Client) error
+
+// Use the auth server at the given base URL, for example StageServer or
+// "https://fxa.example.com/v1". All API paths are resolved relative to it.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("fxa: unsupported base URL scheme %q", u.Scheme)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("fxa: base URL %q has no host", baseURL)
+		}
+		if u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("fxa: base URL %q must not have a query or fragment", baseURL)
+		}
+		c.baseURL = strings.TrimRight(u.String(), "/")
+		return nil
+	}
+}
+
+// Send all requests through the given HTTP client. This is the place to
+// configure timeouts, proxies, TLS settings and connection pooling. The client
+// is shared, not copied, so one can be reused by many Clients.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("fxa: nil http.Client")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// Send all requests through the given transport. Other settings of the HTTP
+// client, like a Timeout set with WithHTTPClient, are preserved.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		if transport == nil {
+			return errors.New("fxa: nil http.RoundTripper")
+		}
+		httpClient := *c.httpClient
+		httpClient.Transport = transport
+		c.httpClient = &httpClient
+		return nil
+	}
+}
+
+// Default limit on the size of response bodies.
+const DefaultMaxResponseSize = 1 << 20
+
+// Limit the size of response bodies. Calls with larger responses fail with
+// ErrResponseTooLarge, or with an *HTTPError for error responses.
+func WithMaxResponseSize(size int64) ClientOption {
+	return func(c *Client) error {
+		if size <= 0 {
+			return errors.New("fxa: max response size must be positive")
+		}
+		c.maxResponseSize = size
+		return nil
+	}
+}
+
+// Reject responses to Hawk signed calls unless their Server-Authorization
+// header carries a valid MAC and payload hash. This detects responses that
+// were tampered with by intermediaries, but only works against auth servers
+// that sign their responses.
+func WithResponseVerification() ClientOption {
+	return func(c *Client) error {
+		c.verifyHawk = true
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_NewClientWithOptions_Default(t *testing.T) {
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234")
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+	if client.endpoint("/account/login") != "https://api.accounts.firefox.com/v1/account/login" {
+		t.Error("Unexpected default endpoint: ", client.endpoint("/account/login"))
+	}
+}
+
+func Test_WithBaseURL(t *testing.T) {
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(StageServer))
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+	if client.endpoint("/account/keys") != "https://api-accounts.stage.mozaws.net/v1/account/keys" {
+		t.Error("Unexpected stage endpoint: ", client.endpoint("/account/keys"))
+	}
+}
+
+func Test_WithBaseURL_TrailingSlash(t *testing.T) {
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL("http://localhost:9000/v1/"))
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+	if client.endpoint("/certificate/sign") != "http://localhost:9000/v1/certificate/sign" {
+		t.Error("Unexpected endpoint: ", client.endpoint("/certificate/sign"))
+	}
+}
+
+func Test_WithBaseURL_Invalid(t *testing.T) {
+	for _, baseURL := range []string{"ftp://example.com/v1", "/v1", "https://example.com/v1?foo=bar", "http://%zz"} {
+		if _, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(baseURL)); err == nil {
+			t.Error("Expected an error for ", baseURL)
+		}
+	}
+}
+
+func Test_WithHTTPClient(t *testing.T) {
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithHTTPClient(httpClient))
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+	if client.httpClient != httpClient {
+		t.Error("HTTP client was not used")
+	}
+	if _, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithHTTPClient(nil)); err == nil {
+		t.Error("Expected an error for a nil http.Client")
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func Test_WithTransport(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	transport := &countingTransport{}
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	client := newTestClient(t, ts, "secret1234", WithHTTPClient(httpClient), WithTransport(transport))
+
+	if client.httpClient.Timeout != 5*time.Second {
+		t.Error("Timeout of the HTTP client was not preserved")
+	}
+	if httpClient.Transport != nil {
+		t.Error("HTTP client passed to WithHTTPClient was modified")
+	}
+
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if err := client.FetchKeys(); err != nil {
+		t.Fatal("Cannot fetch keys: ", err)
+	}
+	if transport.requests != 2 {
+		t.Errorf("Expected 2 requests through the transport. Got %d", transport.requests)
+	}
+}
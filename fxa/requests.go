@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"crypto/dsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (c *Client) loginAPIRequest() (*apiRequest, error) {
+	request := loginRequest{
+		Email:  c.email,
+		AuthPW: hex.EncodeToString(c.authPW),
+	}
+	encodedRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return &apiRequest{method: "POST", path: "/account/login?keys=true", body: encodedRequest}, nil
+}
+
+func (c *Client) fetchKeysAPIRequest() (*apiRequest, error) {
+	c.mu.RLock()
+	keyFetchToken := c.keyFetchToken
+	c.mu.RUnlock()
+
+	if keyFetchToken == nil {
+		return nil, ErrNotLoggedIn
+	}
+	// Not idempotent: the server deletes the single-use keyFetchToken when it
+	// returns the bundle, so a retry after a lost response can only fail.
+	return &apiRequest{method: "GET", path: "/account/keys", token: keyFetchToken, tokenName: "keyFetchToken"}, nil
+}
+
+func (c *Client) signCertificateAPIRequest(key *dsa.PrivateKey) (*apiRequest, error) {
+	c.mu.RLock()
+	sessionToken := c.sessionToken
+	c.mu.RUnlock()
+
+	if sessionToken == nil {
+		return nil, ErrNotLoggedIn
+	}
+	request := signCertificateRequest{
+		PublicKey: publicKey{
+			Algorithm: "DS",
+			Y:         fmt.Sprintf("%x", key.PublicKey.Y),
+			P:         fmt.Sprintf("%x", key.PublicKey.Parameters.P),
+			Q:         fmt.Sprintf("%x", key.PublicKey.Parameters.Q),
+			G:         fmt.Sprintf("%x", key.PublicKey.Parameters.G),
+		},
+		Duration: 86400000,
+	}
+	encodedRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return &apiRequest{method: "POST", path: "/certificate/sign", body: encodedRequest, token: sessionToken, tokenName: "sessionToken", idempotent: true}, nil
+}
+
+// Returns the request that LoginContext would send, without sending it.
+func (c *Client) NewLoginRequest(ctx context.Context) (*http.Request, error) {
+	ar, err := c.loginAPIRequest()
+	if err != nil {
+		return nil, err
+	}
+	req, _, err := c.newHTTPRequest(ctx, ar)
+	return req, err
+}
+
+// Returns the Hawk signed request that FetchKeysContext would send, without
+// sending it. The signature includes a timestamp, so the request has to be
+// sent within a minute or so.
+func (c *Client) NewFetchKeysRequest(ctx context.Context) (*http.Request, error) {
+	ar, err := c.fetchKeysAPIRequest()
+	if err != nil {
+		return nil, err
+	}
+	req, _, err := c.newHTTPRequest(ctx, ar)
+	return req, err
+}
+
+// Returns the Hawk signed request that SignCertificateContext would send,
+// without sending it. The signature includes a timestamp, so the request has
+// to be sent within a minute or so.
+func (c *Client) NewSignCertificateRequest(ctx context.Context, key *dsa.PrivateKey) (*http.Request, error) {
+	ar, err := c.signCertificateAPIRequest(key)
+	if err != nil {
+		return nil, err
+	}
+	req, _, err := c.newHTTPRequest(ctx, ar)
+	return req, err
+}
@@ -0,0 +1,120 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/st3fan/gofxa/hawk"
+)
+
+func Test_NewLoginRequest(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+	req, err := client.NewLoginRequest(context.Background())
+	if err != nil {
+		t.Fatal("Cannot build login request: ", err)
+	}
+
+	if req.Method != "POST" || req.URL.String() != ts.URL()+"/account/login?keys=true" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected request: %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("Login request should not be signed")
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	request := loginRequest{}
+	if err := json.Unmarshal(body, &request); err != nil || request.Email != "gofxa@sateh.com" || request.AuthPW != hex.EncodeToString(ts.authPW) {
+		t.Error("Unexpected request body: ", string(body))
+	}
+}
+
+func Test_NewFetchKeysRequest(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234")
+	if _, err := client.NewFetchKeysRequest(context.Background()); err != ErrNotLoggedIn {
+		t.Error("Expected ErrNotLoggedIn. Got ", err)
+	}
+
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	req, err := client.NewFetchKeysRequest(context.Background())
+	if err != nil {
+		t.Fatal("Cannot build keys request: ", err)
+	}
+	if atomic.LoadInt32(&ts.keysRequests) != 0 {
+		t.Error("Request was sent")
+	}
+
+	requestCredentials, _ := newRequestCredentials(ts.keyFetchToken, "keyFetchToken")
+	verifier := hawk.NewVerifier(hawk.CredentialsLookupFunc(func(id string) (*hawk.Credentials, error) {
+		if id != hex.EncodeToString(requestCredentials.TokenId) {
+			return nil, hawk.ErrUnknownCredentials
+		}
+		credentials := hawk.NewCredentials(id, requestCredentials.RequestHMACKey)
+		return &credentials, nil
+	}))
+	if _, err := verifier.Authenticate(req); err != nil {
+		t.Error("Request is not signed correctly: ", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Cannot send request: ", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Error("Unexpected status: ", res.Status)
+	}
+}
+
+func Test_NewSignCertificateRequest(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	client := newTestClient(t, ts, "secret1234")
+	if _, err := client.NewSignCertificateRequest(context.Background(), key); err != ErrNotLoggedIn {
+		t.Error("Expected ErrNotLoggedIn. Got ", err)
+	}
+
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	req, err := client.NewSignCertificateRequest(context.Background(), key)
+	if err != nil {
+		t.Fatal("Cannot build certificate request: ", err)
+	}
+	if req.Method != "POST" || req.URL.String() != ts.URL()+"/certificate/sign" || req.Header.Get("Authorization") == "" {
+		t.Errorf("Unexpected request: %s %s", req.Method, req.URL)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Cannot send request: ", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Error("Unexpected status: ", res.Status)
+	}
+}
@@ -0,0 +1,122 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A RetryPolicy controls how API calls that failed are retried.
+//
+// Throttled calls (errno 114 or status 429) are retried for every endpoint,
+// since the server rejected them without doing any work. Calls that failed
+// with a 5xx status or a network error are only retried for endpoints that are
+// safe to repeat. That excludes Login, because it creates a new session, and
+// FetchKeys, because its keyFetchToken can only be used once.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one. Zero or one
+	// disables retries.
+	MaxAttempts int
+	// Backoff before the first retry. It doubles with every attempt and a
+	// random jitter of up to half its value is subtracted.
+	InitialBackoff time.Duration
+	// Upper bound for the backoff. A throttled call is not retried when the
+	// server asks to wait longer than this. Zero means no upper bound.
+	MaxBackoff time.Duration
+}
+
+// A retry policy suitable for batch jobs.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+}
+
+// Retry failed API calls according to the given policy. By default calls are
+// not retried.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+// Returns the exponential backoff with jitter for the given attempt, which
+// starts at 1 for the first retry.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		if backoff > math.MaxInt64/2 {
+			backoff = math.MaxInt64
+			break
+		}
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff - time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
+
+// Decide whether a failed call should be retried and how long to wait before
+// doing so. The attempt is the number of attempts made so far.
+func (p RetryPolicy) delay(attempt int, ar *apiRequest, res *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	backoff := p.backoff(attempt)
+
+	if res == nil {
+		return backoff, ar.idempotent
+	}
+
+	errorResponse, _ := err.(*ErrorResponse)
+	if res.StatusCode == http.StatusTooManyRequests || (errorResponse != nil && errorResponse.Errno == ErrnoThrottled) {
+		retryAfter := retryAfter(res, errorResponse)
+		if p.MaxBackoff > 0 && retryAfter > p.MaxBackoff {
+			return 0, false
+		}
+		if retryAfter > backoff {
+			return retryAfter, true
+		}
+		return backoff, true
+	}
+
+	if res.StatusCode >= 500 {
+		return backoff, ar.idempotent
+	}
+
+	return 0, false
+}
+
+// Returns how long the server asked us to wait, from the retryAfter field of
+// the error or else the Retry-After header.
+func retryAfter(res *http.Response, errorResponse *ErrorResponse) time.Duration {
+	if errorResponse != nil && errorResponse.RetryAfter > 0 {
+		return time.Duration(errorResponse.RetryAfter) * time.Second
+	}
+
+	header := res.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package fxa
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     10 * time.Millisecond,
+}
+
+// Wraps the handler of the test server so that the first failures requests
+// are answered with the given status and body. Returns the request counter.
+func failFirstRequests(ts *testServer, failures int32, status int, header http.Header, body string) *int32 {
+	var requests int32
+	next := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			for name, values := range header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	return &requests
+}
+
+func Test_Retry_ThrottledLogin(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	requests := failFirstRequests(ts, 2, http.StatusTooManyRequests, nil, `{"code":429,"errno":114,"error":"Too Many Requests","message":"Client has sent too many requests","retryAfter":0}`)
+
+	client := newTestClient(t, ts, "secret1234", WithRetryPolicy(testRetryPolicy))
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests. Got %d", *requests)
+	}
+}
+
+func Test_Retry_ThrottledTooLong(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	requests := failFirstRequests(ts, 1, http.StatusTooManyRequests, nil, `{"code":429,"errno":114,"error":"Too Many Requests","message":"Client has sent too many requests","retryAfter":900}`)
+
+	client := newTestClient(t, ts, "secret1234", WithRetryPolicy(testRetryPolicy))
+	err := client.Login()
+	errorResponse, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("Expected an fxa.ErrorResponse. Got %#v", err)
+	}
+	if errorResponse.Errno != 114 || errorResponse.RetryAfter != 900 {
+		t.Errorf("Unexpected error received: %#v", errorResponse)
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 request. Got %d", *requests)
+	}
+}
+
+func Test_Retry_ServerErrorNotIdempotent(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	requests := failFirstRequests(ts, 1, http.StatusServiceUnavailable, nil, `{"code":503,"errno":201,"error":"Service Unavailable","message":"Service unavailable"}`)
+
+	client := newTestClient(t, ts, "secret1234", WithRetryPolicy(testRetryPolicy))
+	if err := client.Login(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 request. Got %d", *requests)
+	}
+}
+
+func Test_Retry_ServerErrorFetchKeys(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234", WithRetryPolicy(testRetryPolicy))
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	requests := failFirstRequests(ts, 1, http.StatusBadGateway, nil, `<html>Bad Gateway</html>`)
+
+	var httpError *HTTPError
+	if err := client.FetchKeys(); !errors.As(err, &httpError) || httpError.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Expected an *HTTPError with status 502. Got %#v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 request. Got %d", *requests)
+	}
+}
+
+func Test_Retry_ServerErrorIdempotent(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234", WithRetryPolicy(testRetryPolicy))
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	requests := failFirstRequests(ts, 2, http.StatusBadGateway, nil, `<html>Bad Gateway</html>`)
+
+	if _, err := client.SignCertificate(key); err != nil {
+		t.Fatal("Cannot sign certificate: ", err)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests. Got %d", *requests)
+	}
+}
+
+func Test_Retry_MaxAttempts(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	client := newTestClient(t, ts, "secret1234", WithRetryPolicy(testRetryPolicy))
+	if err := client.Login(); err != nil {
+		t.Fatal("Cannot login: ", err)
+	}
+
+	key, err := generateRandomKey()
+	if err != nil {
+		t.Fatal("Cannot generate key: ", err)
+	}
+
+	requests := failFirstRequests(ts, 10, http.StatusInternalServerError, nil, `{"code":500,"errno":999,"error":"Internal Server Error"}`)
+
+	if _, err := client.SignCertificate(key); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests. Got %d", *requests)
+	}
+}
+
+func Test_Retry_Disabled(t *testing.T) {
+	ts := newTestServer(t, "gofxa@sateh.com", "secret1234")
+	defer ts.Close()
+
+	requests := failFirstRequests(ts, 1, http.StatusTooManyRequests, nil, `{"code":429,"errno":114,"error":"Too Many Requests","retryAfter":0}`)
+
+	client := newTestClient(t, ts, "secret1234")
+	if err := client.Login(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 request. Got %d", *requests)
+	}
+}
+
+func Test_Retry_CancelDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"code":429,"errno":114,"error":"Too Many Requests"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClientWithOptions("gofxa@sateh.com", "secret1234", WithBaseURL(server.URL+"/v1"), WithRetryPolicy(DefaultRetryPolicy))
+	if err != nil {
+		t.Fatal("Cannot create client: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.LoginContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded. Got %#v", err)
+	}
+}
+
+func Test_RetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		backoff := policy.backoff(attempt + 1)
+		if backoff < max/2 || backoff > max {
+			t.Errorf("Backoff %v for attempt %d is not within [%v, %v]", backoff, attempt+1, max/2, max)
+		}
+	}
+
+	policy.MaxBackoff = 0
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond} {
+		backoff := policy.backoff(attempt + 1)
+		if backoff < max/2 || backoff > max {
+			t.Errorf("Uncapped backoff %v for attempt %d is not within [%v, %v]", backoff, attempt+1, max/2, max)
+		}
+	}
+
+	if backoff := policy.backoff(100); backoff < math.MaxInt64/2 {
+		t.Error("Uncapped backoff overflowed: ", backoff)
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	res := &http.Response{Header: http.Header{}}
+	if d := retryAfter(res, nil); d != 0 {
+		t.Error("Expected no delay. Got ", d)
+	}
+
+	res.Header.Set("Retry-After", "7")
+	if d := retryAfter(res, nil); d != 7*time.Second {
+		t.Error("Expected 7s. Got ", d)
+	}
+	if d := retryAfter(res, &ErrorResponse{RetryAfter: 3}); d != 3*time.Second {
+		t.Error("Expected 3s from the error response. Got ", d)
+	}
+
+	res.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if d := retryAfter(res, nil); d <= 0 || d > time.Minute {
+		t.Error("Expected a delay of up to a minute. Got ", d)
+	}
+}
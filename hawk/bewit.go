@@ -0,0 +1,157 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidBewit = errors.New("hawk: invalid bewit")
+	ErrExpiredBewit = errors.New("hawk: expired bewit")
+)
+
+// Returns a bewit that grants GET access to the URL until the ttl expires.
+// The bewit is meant for the bewit query parameter of the URL.
+func (s *Signer) Bewit(u *url.URL, ttl time.Duration, ext string) (string, error) {
+	if strings.Contains(s.Credentials.id, `\`) || strings.Contains(ext, `\`) {
+		return "", fmt.Errorf("%w: id and ext cannot contain a backslash", ErrInvalidBewit)
+	}
+
+	exp := s.timestamp().Add(ttl).Unix()
+
+	req := &http.Request{Method: "GET", URL: u}
+	artifacts, err := newArtifacts(req, exp, "", "", ext)
+	if err != nil {
+		return "", err
+	}
+	mac := hawkMAC(s.Credentials.hash(), s.Credentials.key, "bewit", artifacts)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(s.Credentials.id + `\` + strconv.FormatInt(exp, 10) + `\` + mac + `\` + ext)), nil
+}
+
+// Returns a copy of the URL with a bewit query parameter that grants GET
+// access to it until the ttl expires.
+func (s *Signer) SignURL(u *url.URL, ttl time.Duration, ext string) (*url.URL, error) {
+	bewit, err := s.Bewit(u, ttl, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := *u
+	if signed.RawQuery != "" {
+		signed.RawQuery += "&"
+	}
+	signed.RawQuery += "bewit=" + bewit
+
+	return &signed, nil
+}
+
+// Split the bewit query parameter from a request URI. Returns the request URI
+// without it.
+func stripBewit(requestURI string) (string, string, bool) {
+	i := strings.IndexByte(requestURI, '?')
+	if i == -1 {
+		return requestURI, "", false
+	}
+	path, query := requestURI[:i], requestURI[i+1:]
+
+	var bewit string
+	var found bool
+	var params []string
+	for _, param := range strings.Split(query, "&") {
+		if strings.HasPrefix(param, "bewit=") && !found {
+			bewit, found = param[len("bewit="):], true
+			continue
+		}
+		params = append(params, param)
+	}
+	if !found {
+		return requestURI, "", false
+	}
+
+	if len(params) == 0 {
+		return path, bewit, true
+	}
+	return path + "?" + strings.Join(params, "&"), bewit, true
+}
+
+// Returns true if the request URL has a bewit query parameter.
+func hasBewit(req *http.Request) bool {
+	_, _, ok := stripBewit(req.URL.RequestURI())
+	return ok
+}
+
+// Authenticate a GET or HEAD request from the bewit query parameter in its
+// URL. The bewit is removed from req.URL and req.RequestURI so that handlers
+// see the URL that was signed. Returns the credentials of the client.
+func (v *Verifier) AuthenticateBewit(req *http.Request) (*Credentials, error) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, fmt.Errorf("%w: method not allowed", ErrInvalidBewit)
+	}
+	if req.Header.Get("Authorization") != "" {
+		return nil, fmt.Errorf("%w: multiple authentications", ErrInvalidBewit)
+	}
+
+	requestURI := req.URL.RequestURI()
+	if strings.HasPrefix(req.RequestURI, "/") {
+		requestURI = req.RequestURI
+	}
+	resource, encoded, ok := stripBewit(requestURI)
+	if !ok || encoded == "" {
+		return nil, fmt.Errorf("%w: missing bewit", ErrInvalidBewit)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid encoding", ErrInvalidBewit)
+	}
+	parts := strings.Split(string(decoded), `\`)
+	if len(parts) != 4 || parts[0] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("%w: invalid structure", ErrInvalidBewit)
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid expiry", ErrInvalidBewit)
+	}
+
+	if v.timestamp().Unix() >= exp {
+		return nil, ErrExpiredBewit
+	}
+
+	credentials, err := v.lookupCredentials(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts, err := v.newArtifacts(req, exp, "", "", parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBewit, err)
+	}
+	artifacts.method = "GET"
+	artifacts.resource = resource
+	if !hmac.Equal([]byte(hawkMAC(credentials.hash(), credentials.key, "bewit", artifacts)), []byte(parts[2])) {
+		return nil, ErrBadMAC
+	}
+
+	stripped, err := url.ParseRequestURI(resource)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBewit, err)
+	}
+	req.URL.Path, req.URL.RawPath, req.URL.RawQuery = stripped.Path, stripped.RawPath, stripped.RawQuery
+	if req.RequestURI != "" {
+		req.RequestURI = resource
+	}
+
+	return credentials, nil
+}
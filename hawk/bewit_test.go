@@ -0,0 +1,142 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_Bewit(t *testing.T) {
+	signer := NewSigner(NewCredentials("123456", []byte("2983d45yun89q")))
+	signer.now = func() time.Time { return time.Unix(1356420407, 0) }
+
+	u, _ := url.Parse("https://example.com/somewhere/over/the/rainbow")
+	bewit, err := signer.Bewit(u, 300*time.Second, "xandyandz")
+	if err != nil || bewit != "MTIzNDU2XDEzNTY0MjA3MDdca3NjeHdOUjJ0SnBQMVQxekRMTlBiQjVVaUtJVTl0T1NKWFRVZEc3WDloOD1ceGFuZHlhbmR6" {
+		t.Error("Unexpected bewit: ", err, bewit)
+	}
+}
+
+func Test_stripBewit(t *testing.T) {
+	for _, test := range []struct{ requestURI, resource, bewit string }{
+		{"/resource?bewit=abc", "/resource", "abc"},
+		{"/resource?a=1&bewit=abc", "/resource?a=1", "abc"},
+		{"/resource?bewit=abc&a=1&b=2", "/resource?a=1&b=2", "abc"},
+	} {
+		resource, bewit, ok := stripBewit(test.requestURI)
+		if !ok || resource != test.resource || bewit != test.bewit {
+			t.Errorf("Unexpected result for %s: %s %s", test.requestURI, resource, bewit)
+		}
+	}
+	if _, _, ok := stripBewit("/resource?a=1&notbewit=abc"); ok {
+		t.Error("Did not expect a bewit")
+	}
+}
+
+func newBewitTestVerifier(now time.Time) *Verifier {
+	verifier := NewVerifier(CredentialsLookupFunc(func(id string) (*Credentials, error) {
+		if id != "123456" {
+			return nil, ErrUnknownCredentials
+		}
+		credentials := NewCredentials("123456", []byte("2983d45yun89q"))
+		return &credentials, nil
+	}))
+	verifier.now = func() time.Time { return now }
+	return verifier
+}
+
+func Test_AuthenticateBewit(t *testing.T) {
+	verifier := newBewitTestVerifier(time.Unix(1356420407, 0))
+
+	request := httptest.NewRequest("GET", "https://example.com/somewhere/over/the/rainbow?bewit=MTIzNDU2XDEzNTY0MjA3MDdca3NjeHdOUjJ0SnBQMVQxekRMTlBiQjVVaUtJVTl0T1NKWFRVZEc3WDloOD1ceGFuZHlhbmR6", nil)
+	credentials, err := verifier.AuthenticateBewit(request)
+	if err != nil || credentials.ID() != "123456" {
+		t.Fatal("AuthenticateBewit failed: ", err)
+	}
+	if request.URL.RawQuery != "" || request.RequestURI != "/somewhere/over/the/rainbow" {
+		t.Error("Bewit was not stripped: ", request.URL, request.RequestURI)
+	}
+}
+
+func Test_AuthenticateBewit_Expired(t *testing.T) {
+	verifier := newBewitTestVerifier(time.Unix(1356420707, 0))
+
+	request := httptest.NewRequest("GET", "https://example.com/somewhere/over/the/rainbow?bewit=MTIzNDU2XDEzNTY0MjA3MDdca3NjeHdOUjJ0SnBQMVQxekRMTlBiQjVVaUtJVTl0T1NKWFRVZEc3WDloOD1ceGFuZHlhbmR6", nil)
+	if _, err := verifier.AuthenticateBewit(request); err != ErrExpiredBewit {
+		t.Error("Expected ErrExpiredBewit. Got ", err)
+	}
+}
+
+func Test_AuthenticateBewit_Invalid(t *testing.T) {
+	verifier := newBewitTestVerifier(time.Unix(1356420407, 0))
+
+	for _, test := range []struct {
+		method, url string
+		err         error
+	}{
+		{"POST", "https://example.com/somewhere/over/the/rainbow?bewit=MTIzNDU2XDEzNTY0MjA3MDdca3NjeHdOUjJ0SnBQMVQxekRMTlBiQjVVaUtJVTl0T1NKWFRVZEc3WDloOD1ceGFuZHlhbmR6", ErrInvalidBewit},
+		{"GET", "https://example.com/somewhere/over/the/rainbow?bewit=!!!", ErrInvalidBewit},
+		{"GET", "https://example.com/somewhere/over/the/rainbow?bewit=MTIzNDU2XDEzNTY0MjA3MDc", ErrInvalidBewit},
+		{"GET", "https://example.com/somewhere/over/the/rainbow/2?bewit=MTIzNDU2XDEzNTY0MjA3MDdca3NjeHdOUjJ0SnBQMVQxekRMTlBiQjVVaUtJVTl0T1NKWFRVZEc3WDloOD1ceGFuZHlhbmR6", ErrBadMAC},
+	} {
+		request := httptest.NewRequest(test.method, test.url, nil)
+		if _, err := verifier.AuthenticateBewit(request); !errors.Is(err, test.err) {
+			t.Errorf("Expected %v for %s %s. Got %v", test.err, test.method, test.url, err)
+		}
+	}
+}
+
+func Test_Verifier_Bewit(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.AllowBewit = true
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	})))
+	defer server.Close()
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	u, _ := url.Parse(server.URL + "/export?format=json")
+	signed, err := signer.SignURL(u, time.Minute, "")
+	if err != nil {
+		t.Fatal("SignURL failed: ", err)
+	}
+
+	response, err := http.Get(signed.String())
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || string(body) != "/export?format=json" {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, body)
+	}
+}
+
+func Test_Verifier_BewitNotAllowed(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	u, _ := url.Parse(server.URL + "/export")
+	signed, err := signer.SignURL(u, time.Minute, "")
+	if err != nil {
+		t.Fatal("SignURL failed: ", err)
+	}
+
+	response, err := http.Get(signed.String())
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unexpected response: %d", response.StatusCode)
+	}
+}
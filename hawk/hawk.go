@@ -0,0 +1,293 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+// Package hawk implements the Hawk HTTP authentication scheme, for signing
+// requests as a client and authenticating them as a server.
+package hawk
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The hash algorithm that Hawk uses for MACs and payload hashes.
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "sha1"
+	SHA256 Algorithm = "sha256"
+)
+
+// Returns the hash function of the algorithm, or nil if it is not supported.
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case SHA1:
+		return sha1.New
+	case SHA256:
+		return sha256.New
+	}
+	return nil
+}
+
+// Hawk credentials: an id, the key that is shared with the server and the
+// algorithm that is used with the key.
+type Credentials struct {
+	id        string
+	key       []byte
+	algorithm Algorithm
+}
+
+// Returns the hash function of the credentials. Credentials without an
+// algorithm use sha256.
+func (hc *Credentials) hash() func() hash.Hash {
+	if hc.algorithm == "" {
+		return sha256.New
+	}
+	return hc.algorithm.hash()
+}
+
+// Returns a random nonce of 8 characters.
+func randomNonce() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func portForURL(u *url.URL) (int, error) {
+	if strings.LastIndex(u.Host, ":") == -1 {
+		if u.Scheme == "http" {
+			return 80, nil
+		} else {
+			return 443, nil
+		}
+	} else {
+		_, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(port)
+	}
+}
+
+func hostForURL(u *url.URL) (string, error) {
+	if strings.LastIndex(u.Host, ":") == -1 {
+		return u.Host, nil
+	} else {
+		host, _, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return "", err
+		}
+		return host, nil
+	}
+}
+
+// The values that go into the normalized string of a Hawk MAC.
+type hawkArtifacts struct {
+	method   string
+	resource string
+	host     string
+	port     int
+	ts       int64
+	nonce    string
+	hash     string
+	ext      string
+	app      string
+	dlg      string
+}
+
+// Returns the URL that the request is sent to, or was received at, with only
+// the scheme and host set. Incoming requests have the host in req.Host.
+func hawkURLForRequest(req *http.Request) *url.URL {
+	u := &url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host}
+	if req.Host != "" {
+		u.Host = req.Host
+	}
+	if u.Scheme == "" {
+		if req.TLS != nil {
+			u.Scheme = "https"
+		} else {
+			u.Scheme = "http"
+		}
+	}
+	return u
+}
+
+func newArtifacts(req *http.Request, ts int64, nonce string, payloadHash string, ext string) (*hawkArtifacts, error) {
+	u := hawkURLForRequest(req)
+
+	port, err := portForURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := hostForURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := req.URL.RequestURI()
+	if strings.HasPrefix(req.RequestURI, "/") {
+		resource = req.RequestURI
+	}
+
+	return &hawkArtifacts{
+		method:   req.Method,
+		resource: resource,
+		host:     host,
+		port:     port,
+		ts:       ts,
+		nonce:    nonce,
+		hash:     payloadHash,
+		ext:      ext,
+	}, nil
+}
+
+var hawkExtReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// Returns the normalized string of the given type, which is "header" for
+// requests or "response" for responses. The app and dlg lines are only
+// present for application delegation.
+func hawkNormalizedString(kind string, artifacts *hawkArtifacts) string {
+	var b strings.Builder
+	b.WriteString("hawk.1." + kind + "\n")
+	b.WriteString(strconv.FormatInt(artifacts.ts, 10) + "\n")
+	b.WriteString(artifacts.nonce + "\n")
+	b.WriteString(artifacts.method + "\n")
+	b.WriteString(artifacts.resource + "\n")
+	b.WriteString(artifacts.host + "\n")
+	b.WriteString(strconv.Itoa(artifacts.port) + "\n")
+	b.WriteString(artifacts.hash + "\n")
+	b.WriteString(hawkExtReplacer.Replace(artifacts.ext) + "\n")
+	if artifacts.app != "" {
+		b.WriteString(artifacts.app + "\n")
+		b.WriteString(artifacts.dlg + "\n")
+	}
+	return b.String()
+}
+
+// Calculate the MAC over the normalized string of the given type.
+func hawkMAC(newHash func() hash.Hash, key []byte, kind string, artifacts *hawkArtifacts) string {
+	mac := hmac.New(newHash, key)
+	io.WriteString(mac, hawkNormalizedString(kind, artifacts))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hawkPayloadHash(newHash func() hash.Hash, req *http.Request, payload io.Reader) (string, error) {
+	return hawkContentPayloadHash(newHash, req.Header.Get("Content-Type"), payload)
+}
+
+// Returns the content type as it goes into the payload hash: the lowercased
+// media type without parameters.
+func hawkContentType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+func hawkContentPayloadHash(newHash func() hash.Hash, contentType string, payload io.Reader) (string, error) {
+	if payload == nil {
+		return "", nil
+	}
+	hash := newHash()
+	io.WriteString(hash, "hawk.1.payload\n")
+	io.WriteString(hash, hawkContentType(contentType)+"\n")
+	if _, err := io.Copy(hash, payload); err != nil {
+		return "", err
+	}
+	io.WriteString(hash, "\n")
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Create Hawk credentials with the given id and key that use sha256.
+func NewCredentials(id string, key []byte) Credentials {
+	return Credentials{id: id, key: key, algorithm: SHA256}
+}
+
+// Create Hawk credentials with the given id, key and algorithm. Returns
+// ErrUnknownAlgorithm if the algorithm is not sha1 or sha256.
+func NewCredentialsWithAlgorithm(id string, key []byte, algorithm Algorithm) (Credentials, error) {
+	if algorithm.hash() == nil {
+		return Credentials{}, ErrUnknownAlgorithm
+	}
+	return Credentials{id: id, key: key, algorithm: algorithm}, nil
+}
+
+// Returns the id of the credentials.
+func (hc *Credentials) ID() string {
+	return hc.id
+}
+
+// Returns the algorithm of the credentials.
+func (hc *Credentials) Algorithm() Algorithm {
+	if hc.algorithm == "" {
+		return SHA256
+	}
+	return hc.algorithm
+}
+
+var (
+	ErrMissingHeader  = errors.New("hawk: missing authorization header")
+	ErrInvalidHeader  = errors.New("hawk: invalid authorization header")
+	ErrBadMAC         = errors.New("hawk: bad mac")
+	ErrBadPayloadHash = errors.New("hawk: bad payload hash")
+
+	ErrInvalidAttribute = errors.New("hawk: invalid attribute value")
+	ErrUnknownAlgorithm = errors.New("hawk: unknown algorithm")
+)
+
+// Options for signing a request.
+type RequestOptions struct {
+	// Application specific data.
+	Ext string
+	// Application and delegating application ids for Oz.
+	App string
+	Dlg string
+	// The host and port to sign instead of those of the request URL, for
+	// requests that go through a proxy or gateway that rewrites them. They
+	// must match what the server sees.
+	Host string
+	Port int
+}
+
+// Check that the option values only use the characters that the Hawk
+// specification allows in header values. Quotes and backslashes are rejected
+// because Hawk servers do not unescape them.
+func (o RequestOptions) validate() error {
+	for _, value := range []string{o.Ext, o.App, o.Dlg, o.Host} {
+		if value != "" && !hawkAttributeValueRegexp.MatchString(value) {
+			return ErrInvalidAttribute
+		}
+	}
+	if o.Dlg != "" && o.App == "" {
+		return ErrInvalidAttribute
+	}
+	if o.Port < 0 || o.Port > 65535 {
+		return ErrInvalidAttribute
+	}
+	return nil
+}
+
+// Replace the host and port of the artifacts with the overrides, if set.
+func (o RequestOptions) apply(artifacts *hawkArtifacts) {
+	if o.Host != "" {
+		artifacts.host = o.Host
+	}
+	if o.Port != 0 {
+		artifacts.port = o.Port
+	}
+}
@@ -0,0 +1,318 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_portForURL_HTTP(t *testing.T) {
+	u, _ := url.Parse("http://www.example.com/index.html")
+	if port, err := portForURL(u); err != nil || port != 80 {
+		t.Error()
+	}
+}
+
+func Test_portForURL_HTTPS(t *testing.T) {
+	u, _ := url.Parse("https://www.example.com/index.html")
+	if port, err := portForURL(u); err != nil || port != 443 {
+		t.Error()
+	}
+}
+
+func Test_portForURL_Custom(t *testing.T) {
+	u, _ := url.Parse("http://www.example.com:8080/index.html")
+	if port, err := portForURL(u); err != nil || port != 8080 {
+		t.Error()
+	}
+}
+
+func Test_hawkPayloadHash(t *testing.T) {
+	body := "Thank you for flying Hawk"
+	request, _ := http.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader(body))
+	request.Header.Set("Content-Type", "text/plain")
+	if hash, err := hawkPayloadHash(sha256.New, request, strings.NewReader(body)); err != nil || hash != "Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=" {
+		t.Error()
+	}
+}
+
+func Test_hawkPayloadHash_ContentTypeParameters(t *testing.T) {
+	body := "Thank you for flying Hawk"
+	for _, contentType := range []string{"text/plain; charset=utf-8", "Text/Plain", " text/plain ;format=flowed"} {
+		request, _ := http.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		if hash, err := hawkPayloadHash(sha256.New, request, strings.NewReader(body)); err != nil || hash != "Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=" {
+			t.Error("Unexpected payload hash for ", contentType, ": ", hash)
+		}
+	}
+}
+
+func Test_hawkMAC_GET(t *testing.T) {
+	request, _ := http.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+	artifacts, err := newArtifacts(request, 1353832234, "j4h3g2", "", "some-app-ext-data")
+	if err != nil {
+		t.Fatal("Cannot create artifacts: ", err)
+	}
+	signature := hawkMAC(sha256.New, []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), "header", artifacts)
+	if signature != "6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE=" {
+		t.Error("Request signature failure: ", signature)
+	}
+}
+
+func Test_hawkMAC_POST(t *testing.T) {
+	body := "Thank you for flying Hawk"
+	request, _ := http.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader(body))
+	request.Header.Set("Content-Type", "text/plain")
+
+	hash, err := hawkPayloadHash(sha256.New, request, strings.NewReader(body))
+	if err != nil || hash != "Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=" {
+		t.Error("Payload hash failure")
+	}
+
+	artifacts, err := newArtifacts(request, 1353832234, "j4h3g2", hash, "some-app-ext-data")
+	if err != nil {
+		t.Fatal("Cannot create artifacts: ", err)
+	}
+	signature := hawkMAC(sha256.New, []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), "header", artifacts)
+	if signature != "aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw=" {
+		t.Error("Request signature failure: ", signature)
+	}
+}
+
+func Test_authorizeRequest_GET(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request, _ := http.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+	if err := signer.AuthorizeRequest(request, nil, "some-app-ext-data"); err != nil {
+		t.Error("AuthorizeRequest failed: ", err)
+	}
+
+	authorization := request.Header.Get("Authorization")
+	if authorization == "" {
+		t.Error("No Authorization header")
+	}
+}
+
+func Test_authorizeRequest_POST(t *testing.T) {
+	body := "Thank you for flying Hawk"
+	request, _ := http.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader(body))
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	if err := signer.AuthorizeRequest(request, strings.NewReader(body), "some-app-ext-data"); err != nil {
+		t.Error("AuthorizeRequest failed: ", err)
+	}
+
+	authorization := request.Header.Get("Authorization")
+	if authorization == "" {
+		t.Error("No Authorization header")
+	}
+}
+
+func Test_authorizeRequest_Deterministic(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	signer.now = func() time.Time { return time.Unix(1353832234, 0) }
+	signer.nonce = func() (string, error) { return "j4h3g2", nil }
+
+	request, _ := http.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+	if err := signer.AuthorizeRequest(request, nil, "some-app-ext-data"); err != nil {
+		t.Error("AuthorizeRequest failed: ", err)
+	}
+
+	expected := `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`
+	if authorization := request.Header.Get("Authorization"); authorization != expected {
+		t.Error("Unexpected Authorization header: ", authorization)
+	}
+}
+
+func Test_authorizeRequest_Algorithms(t *testing.T) {
+	tests := []struct {
+		algorithm Algorithm
+		hash      string
+		mac       string
+	}{
+		{SHA1, "lXEo8X7vjnRab2zfS4qKWLFIQAQ=", "bkmsaQtJNgNADJ5Dk5fkWiHSyvU="},
+		{SHA256, "Yi9LfIIFRtBEPt74PVmbTF/xVAwPn7ub15ePICfgnuY=", "aSe1DERmZuRl3pI36/9BdZmnErTw3sNzOOAUlfeKjVw="},
+	}
+
+	for _, test := range tests {
+		credentials, err := NewCredentialsWithAlgorithm("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), test.algorithm)
+		if err != nil {
+			t.Fatal("NewCredentialsWithAlgorithm failed: ", err)
+		}
+		signer := NewSigner(credentials)
+		signer.now = func() time.Time { return time.Unix(1353832234, 0) }
+		signer.nonce = func() (string, error) { return "j4h3g2", nil }
+
+		body := "Thank you for flying Hawk"
+		request, _ := http.NewRequest("POST", "http://example.com:8000/resource/1?b=1&a=2", strings.NewReader(body))
+		request.Header.Set("Content-Type", "text/plain")
+		if err := signer.AuthorizeRequest(request, strings.NewReader(body), "some-app-ext-data"); err != nil {
+			t.Fatal("AuthorizeRequest failed: ", err)
+		}
+
+		authorization, err := ParseAuthorization(request.Header.Get("Authorization"))
+		if err != nil {
+			t.Fatal("ParseAuthorization failed: ", err)
+		}
+		if authorization.Hash != test.hash || authorization.MAC != test.mac {
+			t.Errorf("Unexpected %s hash %s and mac %s", test.algorithm, authorization.Hash, authorization.MAC)
+		}
+	}
+}
+
+func Test_NewCredentialsWithAlgorithm_Unknown(t *testing.T) {
+	if _, err := NewCredentialsWithAlgorithm("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), "md5"); err != ErrUnknownAlgorithm {
+		t.Error("Expected ErrUnknownAlgorithm. Got ", err)
+	}
+	if credentials := NewCredentials("dh37fgj492je", nil); credentials.Algorithm() != SHA256 {
+		t.Error("Expected sha256. Got ", credentials.Algorithm())
+	}
+}
+
+func Test_authorizeRequest_HostPortOverride(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	signer.now = func() time.Time { return time.Unix(1353832234, 0) }
+	signer.nonce = func() (string, error) { return "j4h3g2", nil }
+
+	request, _ := http.NewRequest("GET", "http://127.0.0.1:9000/resource/1?b=1&a=2", nil)
+	options := RequestOptions{Ext: "some-app-ext-data", Host: "example.com", Port: 8000}
+	if err := signer.AuthorizeRequestWithOptions(request, nil, options); err != nil {
+		t.Fatal("AuthorizeRequestWithOptions failed: ", err)
+	}
+
+	expected := `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`
+	if authorization := request.Header.Get("Authorization"); authorization != expected {
+		t.Error("Unexpected Authorization header: ", authorization)
+	}
+
+	request, _ = http.NewRequest("GET", "http://127.0.0.1:9000/resource/1", nil)
+	if err := signer.AuthorizeRequestWithOptions(request, nil, RequestOptions{Port: 70000}); err != ErrInvalidAttribute {
+		t.Error("Expected ErrInvalidAttribute. Got ", err)
+	}
+}
+
+func Test_authorizeRequest_RandomNonce(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+
+	nonces := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		request, _ := http.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+		if err := signer.AuthorizeRequest(request, nil, ""); err != nil {
+			t.Fatal("AuthorizeRequest failed: ", err)
+		}
+		match := regexp.MustCompile(`nonce="([^"]+)"`).FindStringSubmatch(request.Header.Get("Authorization"))
+		if match == nil || len(match[1]) != 8 {
+			t.Fatal("Unexpected Authorization header: ", request.Header.Get("Authorization"))
+		}
+		if nonces[match[1]] {
+			t.Fatal("Nonce was reused: ", match[1])
+		}
+		nonces[match[1]] = true
+	}
+}
+
+func newResponseTest() (*http.Request, *http.Response) {
+	request, _ := http.NewRequest("POST", "http://example.com:8080/resource/4?filter=a", nil)
+	request.Header.Set("Authorization", `Hawk id="123456", ts="1362336900", nonce="eb5S_L", hash="nJjkVtBE5Y/Bk38Aiokwn0jiJxt/0S2WRSUwWLCf5xk=", ext="some-app-data", mac="BlmSe8K+pbKIb6YsZCnt4E1GrYvY1AaYayNR82dGpIk="`)
+	response := &http.Response{Header: http.Header{}}
+	response.Header.Set("Content-Type", "text/plain")
+	response.Header.Set("Server-Authorization", `Hawk mac="XIJRsMl/4oL+nn+vKoeVZPdCHXB4yJkNnBbTbHFZUYE=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+	return request, response
+}
+
+func Test_ValidateResponse(t *testing.T) {
+	signer := NewSigner(NewCredentials("123456", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request, response := newResponseTest()
+	if err := signer.ValidateResponse(request, response, []byte("some reply")); err != nil {
+		t.Error("ValidateResponse failed: ", err)
+	}
+}
+
+func Test_ValidateResponse_BadPayload(t *testing.T) {
+	signer := NewSigner(NewCredentials("123456", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request, response := newResponseTest()
+	if err := signer.ValidateResponse(request, response, []byte("some other reply")); err != ErrBadPayloadHash {
+		t.Error("Expected ErrBadPayloadHash. Got ", err)
+	}
+}
+
+func Test_ValidateResponse_BadMAC(t *testing.T) {
+	signer := NewSigner(NewCredentials("123456", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request, response := newResponseTest()
+	response.Header.Set("Server-Authorization", `Hawk mac="XIJRsMl/4oL+nn+vKoeVZPdCHXB4yJkNnBbTbHFZUYE=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="other-ext"`)
+	if err := signer.ValidateResponse(request, response, []byte("some reply")); err != ErrBadMAC {
+		t.Error("Expected ErrBadMAC. Got ", err)
+	}
+}
+
+func Test_ValidateResponse_Missing(t *testing.T) {
+	signer := NewSigner(NewCredentials("123456", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request, response := newResponseTest()
+	response.Header.Del("Server-Authorization")
+	if err := signer.ValidateResponse(request, response, []byte("some reply")); err != ErrMissingHeader {
+		t.Error("Expected ErrMissingHeader. Got ", err)
+	}
+}
+
+func Test_hawkNormalizedString(t *testing.T) {
+	artifacts := &hawkArtifacts{ts: 1357747017, nonce: "k3k4j5", method: "GET", resource: "/resource/something", host: "example.com", port: 8080}
+	if s := hawkNormalizedString("header", artifacts); s != "hawk.1.header\n1357747017\nk3k4j5\nGET\n/resource/something\nexample.com\n8080\n\n\n" {
+		t.Errorf("Unexpected normalized string: %q", s)
+	}
+
+	artifacts.ext = "this is some app data"
+	artifacts.app = "some-app"
+	artifacts.dlg = "some-dlg"
+	if s := hawkNormalizedString("header", artifacts); s != "hawk.1.header\n1357747017\nk3k4j5\nGET\n/resource/something\nexample.com\n8080\n\nthis is some app data\nsome-app\nsome-dlg\n" {
+		t.Errorf("Unexpected normalized string: %q", s)
+	}
+
+	artifacts.ext = `a\b"c`
+	artifacts.app = ""
+	artifacts.dlg = ""
+	if s := hawkNormalizedString("header", artifacts); s != "hawk.1.header\n1357747017\nk3k4j5\nGET\n/resource/something\nexample.com\n8080\n\na\\\\b\"c\n" {
+		t.Errorf("Unexpected normalized string: %q", s)
+	}
+}
+
+func Test_authorizeRequestWithOptions_App(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	signer.now = func() time.Time { return time.Unix(1353832234, 0) }
+	signer.nonce = func() (string, error) { return "j4h3g2", nil }
+
+	request, _ := http.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+	if err := signer.AuthorizeRequestWithOptions(request, nil, RequestOptions{Ext: "say 'hi'", App: "some-app", Dlg: "some-dlg"}); err != nil {
+		t.Fatal("AuthorizeRequestWithOptions failed: ", err)
+	}
+
+	authorization, err := ParseAuthorization(request.Header.Get("Authorization"))
+	if err != nil {
+		t.Fatal("Cannot parse Authorization header: ", err)
+	}
+	if authorization.Ext != "say 'hi'" || authorization.App != "some-app" || authorization.Dlg != "some-dlg" {
+		t.Errorf("Unexpected authorization: %#v", authorization)
+	}
+
+	artifacts, _ := newArtifacts(request, 1353832234, "j4h3g2", "", "say 'hi'")
+	artifacts.app, artifacts.dlg = "some-app", "some-dlg"
+	if authorization.MAC != hawkMAC(sha256.New, []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), "header", artifacts) {
+		t.Error("Unexpected mac: ", authorization.MAC)
+	}
+}
+
+func Test_authorizeRequestWithOptions_Invalid(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	for _, options := range []RequestOptions{{Ext: "line\nbreak"}, {Ext: "caf\u00e9"}, {Ext: `say "hi"`}, {Ext: `a\b`}, {Dlg: "some-dlg"}, {App: "some\tapp"}} {
+		request, _ := http.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+		if err := signer.AuthorizeRequestWithOptions(request, nil, options); err != ErrInvalidAttribute {
+			t.Errorf("Expected ErrInvalidAttribute for %#v. Got %v", options, err)
+		}
+	}
+}
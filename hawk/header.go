@@ -0,0 +1,143 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The attributes of a Hawk Authorization header.
+type Authorization struct {
+	ID    string
+	TS    int64
+	Nonce string
+	Hash  string
+	Ext   string
+	MAC   string
+	App   string
+	Dlg   string
+}
+
+var (
+	hawkAttributeRegexp      = regexp.MustCompile(`^(\w+)="([^"\\]*)"\s*(?:,\s*|$)`)
+	hawkAttributeValueRegexp = regexp.MustCompile("^[ \\w!#$%&'()*+,\\-./:;<=>?@\\[\\]^`{|}~]+$")
+	hawkTimestampRegexp      = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Parse a Hawk header into its attributes. Only the given attribute names are
+// accepted. Duplicate attributes and values with characters that are not
+// allowed by the Hawk specification are rejected.
+func parseAttributes(header string, allowed ...string) (map[string]string, error) {
+	scheme, rest := header, ""
+	if i := strings.IndexAny(header, " \t"); i != -1 {
+		scheme, rest = header[:i], strings.TrimLeft(header[i:], " \t")
+	}
+	if !strings.EqualFold(scheme, "Hawk") {
+		return nil, fmt.Errorf("%w: unsupported scheme", ErrInvalidHeader)
+	}
+
+	attributes := map[string]string{}
+	for rest != "" {
+		match := hawkAttributeRegexp.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, fmt.Errorf("%w: bad header format", ErrInvalidHeader)
+		}
+		name, value := match[1], match[2]
+
+		known := false
+		for _, a := range allowed {
+			if a == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: unknown attribute %s", ErrInvalidHeader, name)
+		}
+		if !hawkAttributeValueRegexp.MatchString(value) {
+			return nil, fmt.Errorf("%w: bad attribute value %s", ErrInvalidHeader, name)
+		}
+		if _, ok := attributes[name]; ok {
+			return nil, fmt.Errorf("%w: duplicate attribute %s", ErrInvalidHeader, name)
+		}
+
+		attributes[name] = value
+		rest = rest[len(match[0]):]
+	}
+
+	return attributes, nil
+}
+
+// Parse a Hawk Authorization header. The id, ts, nonce and mac attributes are
+// required. Errors wrap ErrInvalidHeader.
+func ParseAuthorization(header string) (*Authorization, error) {
+	attributes, err := parseAttributes(header, "id", "ts", "nonce", "hash", "ext", "mac", "app", "dlg")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"id", "ts", "nonce", "mac"} {
+		if attributes[name] == "" {
+			return nil, fmt.Errorf("%w: missing attribute %s", ErrInvalidHeader, name)
+		}
+	}
+	if attributes["dlg"] != "" && attributes["app"] == "" {
+		return nil, fmt.Errorf("%w: dlg without app", ErrInvalidHeader)
+	}
+
+	if !hawkTimestampRegexp.MatchString(attributes["ts"]) {
+		return nil, fmt.Errorf("%w: bad attribute value ts", ErrInvalidHeader)
+	}
+	ts, err := strconv.ParseInt(attributes["ts"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad attribute value ts", ErrInvalidHeader)
+	}
+
+	return &Authorization{
+		ID:    attributes["id"],
+		TS:    ts,
+		Nonce: attributes["nonce"],
+		Hash:  attributes["hash"],
+		Ext:   attributes["ext"],
+		MAC:   attributes["mac"],
+		App:   attributes["app"],
+		Dlg:   attributes["dlg"],
+	}, nil
+}
+
+// Format the Authorization header. Empty optional attributes are left out.
+func (a *Authorization) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `Hawk id="%s", ts="%d", nonce="%s"`, a.ID, a.TS, a.Nonce)
+	if a.Ext != "" {
+		fmt.Fprintf(&b, `, ext="%s"`, a.Ext)
+	}
+	fmt.Fprintf(&b, `, mac="%s"`, a.MAC)
+	if a.Hash != "" {
+		fmt.Fprintf(&b, `, hash="%s"`, a.Hash)
+	}
+	if a.App != "" {
+		fmt.Fprintf(&b, `, app="%s"`, a.App)
+		if a.Dlg != "" {
+			fmt.Fprintf(&b, `, dlg="%s"`, a.Dlg)
+		}
+	}
+	return b.String()
+}
+
+// Parse a Hawk Server-Authorization header. The mac attribute is required.
+func parseServerAuthorization(header string) (map[string]string, error) {
+	attributes, err := parseAttributes(header, "mac", "hash", "ext")
+	if err != nil {
+		return nil, err
+	}
+	if attributes["mac"] == "" {
+		return nil, fmt.Errorf("%w: missing attribute mac", ErrInvalidHeader)
+	}
+	return attributes, nil
+}
@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_ParseAuthorization(t *testing.T) {
+	header := `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`
+	authorization, err := ParseAuthorization(header)
+	if err != nil {
+		t.Fatal("ParseAuthorization failed: ", err)
+	}
+	expected := Authorization{ID: "dh37fgj492je", TS: 1353832234, Nonce: "j4h3g2", Ext: "some-app-ext-data", MAC: "6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="}
+	if *authorization != expected {
+		t.Errorf("Unexpected authorization: %#v", authorization)
+	}
+	if authorization.String() != header {
+		t.Error("Unexpected header: ", authorization.String())
+	}
+}
+
+func Test_ParseAuthorization_AllAttributes(t *testing.T) {
+	header := `hawk id="123456",ts="1353809207",  nonce="Ygvqdz", hash="nJjkVtBE5Y/Bk38Aiokwn0jiJxt/0S2WRSUwWLCf5xk=", ext="some-app-data", mac="bY5xUn3YGY0tFM/eT+fItYDkTbKMOY6cSwY9hCRYMg4=", app="my-app", dlg="my-authority"`
+	authorization, err := ParseAuthorization(header)
+	if err != nil {
+		t.Fatal("ParseAuthorization failed: ", err)
+	}
+	expected := Authorization{ID: "123456", TS: 1353809207, Nonce: "Ygvqdz", Hash: "nJjkVtBE5Y/Bk38Aiokwn0jiJxt/0S2WRSUwWLCf5xk=", Ext: "some-app-data", MAC: "bY5xUn3YGY0tFM/eT+fItYDkTbKMOY6cSwY9hCRYMg4=", App: "my-app", Dlg: "my-authority"}
+	if *authorization != expected {
+		t.Errorf("Unexpected authorization: %#v", authorization)
+	}
+}
+
+func Test_ParseAuthorization_Invalid(t *testing.T) {
+	for _, header := range []string{
+		``,
+		`Hawk`,
+		`Basic dXNlcjpwYXNz`,
+		`Hawk id="dh37fgj492je`,
+		`Hawk id=dh37fgj492je, ts="1353832234", nonce="j4h3g2", mac="abc"`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", id="other"`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", foo="bar"`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", ext="a\b"`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", ext="say \"hi\""`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", ext="caf` + "é" + `"`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", ext=""`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2"`,
+		`Hawk id="dh37fgj492je", ts="-1353832234", nonce="j4h3g2", mac="abc"`,
+		`Hawk id="dh37fgj492je", ts="soon", nonce="j4h3g2", mac="abc"`,
+		`Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", mac="abc", dlg="my-authority"`,
+		`Hawk id="dh37fgj492je" ts="1353832234" nonce="j4h3g2" mac="abc"`,
+	} {
+		if _, err := ParseAuthorization(header); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("Expected ErrInvalidHeader for %q. Got %v", header, err)
+		}
+	}
+}
+
+func Test_parseServerAuthorization(t *testing.T) {
+	attributes, err := parseServerAuthorization(`Hawk mac="XIJRsMl/4oL+nn+vKoeVZPdCHXB4yJkNnBbTbHFZUYE=", hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=", ext="response-specific"`)
+	if err != nil {
+		t.Fatal("parseServerAuthorization failed: ", err)
+	}
+	if attributes["mac"] != "XIJRsMl/4oL+nn+vKoeVZPdCHXB4yJkNnBbTbHFZUYE=" || attributes["hash"] != "f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM=" || attributes["ext"] != "response-specific" {
+		t.Errorf("Unexpected attributes: %#v", attributes)
+	}
+
+	for _, header := range []string{`Hawk hash="f9cDF/TDm7TkYRLnGwRMfeDzT6LixQVLvrIKhh0vgmM="`, `Hawk mac="abc", id="dh37fgj492je"`} {
+		if _, err := parseServerAuthorization(header); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("Expected ErrInvalidHeader for %q. Got %v", header, err)
+		}
+	}
+}
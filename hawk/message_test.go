@@ -0,0 +1,71 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_AuthorizeMessage(t *testing.T) {
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	signer.now = func() time.Time { return time.Unix(1353832234, 0) }
+	signer.nonce = func() (string, error) { return "j4h3g2", nil }
+
+	authorization, err := signer.AuthorizeMessage("example.com", 8080, []byte("some message"))
+	if err != nil {
+		t.Fatal("AuthorizeMessage failed: ", err)
+	}
+	expected := MessageAuthorization{ID: "dh37fgj492je", TS: 1353832234, Nonce: "j4h3g2", Hash: "FF897AJ2LPnv/0ilMuEgXBWGImE+/9TuSfw1oi4Rsqk=", MAC: "5PxWVRno6YNyIq04avp/6r+C96OOSBVQbli5LzeB7tE="}
+	if *authorization != expected {
+		t.Errorf("Unexpected authorization: %#v", authorization)
+	}
+}
+
+func Test_AuthenticateMessage(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.NonceStore = NewNonceCache(100)
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	authorization, err := signer.AuthorizeMessage("example.com", 8080, []byte("some message"))
+	if err != nil {
+		t.Fatal("AuthorizeMessage failed: ", err)
+	}
+
+	sender, err := verifier.AuthenticateMessage("example.com", 8080, []byte("some message"), authorization)
+	if err != nil || sender.ID() != "dh37fgj492je" {
+		t.Fatal("AuthenticateMessage failed: ", err)
+	}
+
+	if _, err := verifier.AuthenticateMessage("example.com", 8080, []byte("some message"), authorization); err != ErrReplayedNonce {
+		t.Error("Expected ErrReplayedNonce. Got ", err)
+	}
+}
+
+func Test_AuthenticateMessage_Invalid(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	authorization, err := signer.AuthorizeMessage("example.com", 8080, []byte("some message"))
+	if err != nil {
+		t.Fatal("AuthorizeMessage failed: ", err)
+	}
+
+	if _, err := verifier.AuthenticateMessage("example.com", 8080, []byte("other message"), authorization); err != ErrBadPayloadHash {
+		t.Error("Expected ErrBadPayloadHash. Got ", err)
+	}
+	if _, err := verifier.AuthenticateMessage("example.net", 8080, []byte("some message"), authorization); err != ErrBadMAC {
+		t.Error("Expected ErrBadMAC. Got ", err)
+	}
+	if _, err := verifier.AuthenticateMessage("example.com", 8080, []byte("some message"), &MessageAuthorization{ID: "dh37fgj492je"}); !errors.Is(err, ErrInvalidHeader) {
+		t.Error("Expected ErrInvalidHeader. Got ", err)
+	}
+
+	verifier.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if _, err := verifier.AuthenticateMessage("example.com", 8080, []byte("some message"), authorization); err != ErrStaleTimestamp {
+		t.Error("Expected ErrStaleTimestamp. Got ", err)
+	}
+}
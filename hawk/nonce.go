@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrReplayedNonce = errors.New("hawk: replayed nonce")
+
+// A NonceStore remembers the nonces of authenticated requests so that a
+// Verifier can reject replayed requests.
+type NonceStore interface {
+	// Record the nonce for the given id and timestamp. The ttl is how long
+	// the timestamp stays within the allowed clock skew, after which the
+	// nonce may be forgotten. Returns false if the nonce was already
+	// recorded.
+	Add(id string, ts int64, nonce string, ttl time.Duration) (bool, error)
+}
+
+type hawkNonceEntry struct {
+	key     string
+	expires time.Time
+}
+
+// A NonceStore that keeps a bounded number of nonces in memory. When it
+// is full the least recently added nonce is forgotten, so the size should be
+// larger than the number of requests expected within the clock skew window.
+type NonceCache struct {
+	mu      sync.Mutex
+	size    int
+	entries *list.List // Most recently added at the front
+	index   map[string]*list.Element
+	now     func() time.Time
+}
+
+// Create a nonce cache that holds at most size nonces.
+func NewNonceCache(size int) *NonceCache {
+	if size < 1 {
+		size = 1
+	}
+	return &NonceCache{
+		size:    size,
+		entries: list.New(),
+		index:   make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Record the nonce. Returns false if it was already recorded and has not
+// expired yet.
+func (c *NonceCache) Add(id string, ts int64, nonce string, ttl time.Duration) (bool, error) {
+	key := strconv.Quote(id) + " " + strconv.FormatInt(ts, 10) + " " + strconv.Quote(nonce)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+
+	if e, ok := c.index[key]; ok {
+		if now.Before(e.Value.(*hawkNonceEntry).expires) {
+			return false, nil
+		}
+		c.remove(e)
+	}
+
+	for e := c.entries.Back(); e != nil; e = c.entries.Back() {
+		if now.Before(e.Value.(*hawkNonceEntry).expires) && c.entries.Len() < c.size {
+			break
+		}
+		c.remove(e)
+	}
+
+	c.index[key] = c.entries.PushFront(&hawkNonceEntry{key: key, expires: now.Add(ttl)})
+
+	return true, nil
+}
+
+func (c *NonceCache) remove(e *list.Element) {
+	c.entries.Remove(e)
+	delete(c.index, e.Value.(*hawkNonceEntry).key)
+}
+
+// Returns the number of nonces in the cache.
+func (c *NonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
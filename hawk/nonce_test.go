@@ -0,0 +1,105 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_NonceCache_Replay(t *testing.T) {
+	cache := NewNonceCache(10)
+	if ok, err := cache.Add("dh37fgj492je", 1353832234, "j4h3g2", time.Minute); !ok || err != nil {
+		t.Error("Expected a new nonce")
+	}
+	if ok, err := cache.Add("dh37fgj492je", 1353832234, "j4h3g2", time.Minute); ok || err != nil {
+		t.Error("Expected a replayed nonce")
+	}
+	if ok, _ := cache.Add("dh37fgj492je", 1353832235, "j4h3g2", time.Minute); !ok {
+		t.Error("Expected a new nonce for a different timestamp")
+	}
+	if ok, _ := cache.Add("other", 1353832234, "j4h3g2", time.Minute); !ok {
+		t.Error("Expected a new nonce for a different id")
+	}
+}
+
+func Test_NonceCache_Expiry(t *testing.T) {
+	now := time.Unix(1353832234, 0)
+	cache := NewNonceCache(10)
+	cache.now = func() time.Time { return now }
+
+	cache.Add("dh37fgj492je", 1353832234, "j4h3g2", time.Minute)
+	now = now.Add(2 * time.Minute)
+
+	if ok, _ := cache.Add("dh37fgj492je", 1353832234, "j4h3g2", time.Minute); !ok {
+		t.Error("Expected an expired nonce to be forgotten")
+	}
+	if cache.Len() != 1 {
+		t.Error("Expected 1 nonce in the cache. Got ", cache.Len())
+	}
+}
+
+func Test_NonceCache_Bounded(t *testing.T) {
+	cache := NewNonceCache(3)
+	for _, nonce := range []string{"a", "b", "c", "d"} {
+		cache.Add("dh37fgj492je", 1353832234, nonce, time.Minute)
+	}
+	if cache.Len() != 3 {
+		t.Error("Expected 3 nonces in the cache. Got ", cache.Len())
+	}
+	if ok, _ := cache.Add("dh37fgj492je", 1353832234, "d", time.Minute); ok {
+		t.Error("Expected the most recent nonce to be remembered")
+	}
+	if ok, _ := cache.Add("dh37fgj492je", 1353832234, "a", time.Minute); !ok {
+		t.Error("Expected the oldest nonce to be evicted")
+	}
+}
+
+func Test_Verifier_ReplayedNonce(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.NonceStore = NewNonceCache(100)
+	server := newTestServer(verifier)
+	defer server.Close()
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request, _ := http.NewRequest("GET", server.URL+"/resource/1", nil)
+	if err := signer.AuthorizeRequest(request, nil, ""); err != nil {
+		t.Fatal("AuthorizeRequest failed: ", err)
+	}
+
+	for i, status := range []int{http.StatusOK, http.StatusUnauthorized} {
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal("Request failed: ", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != status {
+			t.Errorf("Unexpected status for request %d: %d", i+1, response.StatusCode)
+		}
+		if status == http.StatusUnauthorized && response.Header.Get("WWW-Authenticate") != `Hawk error="Invalid nonce"` {
+			t.Error("Unexpected WWW-Authenticate: ", response.Header.Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func Test_Verifier_ReplayedNonceError(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.NonceStore = NewNonceCache(100)
+
+	signer := NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")))
+	request := httptest.NewRequest("GET", "http://example.com/resource/1", nil)
+	if err := signer.AuthorizeRequest(request, nil, ""); err != nil {
+		t.Fatal("AuthorizeRequest failed: ", err)
+	}
+
+	if _, err := verifier.Authenticate(request); err != nil {
+		t.Fatal("Authenticate failed: ", err)
+	}
+	if _, err := verifier.Authenticate(request); err != ErrReplayedNonce {
+		t.Error("Expected ErrReplayedNonce. Got ", err)
+	}
+}
@@ -0,0 +1,342 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default allowed difference between the client and server clocks.
+const DefaultSkew = 60 * time.Second
+
+// Default limit on the size of request bodies that are read to verify their
+// payload hash.
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	ErrUnknownCredentials = errors.New("hawk: unknown credentials")
+	ErrStaleTimestamp     = errors.New("hawk: stale timestamp")
+	ErrMissingPayloadHash = errors.New("hawk: missing payload hash")
+	ErrBodyTooLarge       = errors.New("hawk: request body too large")
+)
+
+// A CredentialsLookup finds the credentials for a Hawk id. It returns
+// ErrUnknownCredentials, or nil credentials, if there are no credentials with
+// that id. Any other error is treated as an internal server error.
+type CredentialsLookup interface {
+	LookupCredentials(id string) (*Credentials, error)
+}
+
+// Adapter to use an ordinary function as a CredentialsLookup.
+type CredentialsLookupFunc func(id string) (*Credentials, error)
+
+func (f CredentialsLookupFunc) LookupCredentials(id string) (*Credentials, error) {
+	return f(id)
+}
+
+// A Verifier authenticates incoming Hawk signed requests.
+type Verifier struct {
+	// Finds the credentials for the id in the Authorization header.
+	Lookup CredentialsLookup
+	// Allowed difference between the request timestamp and the server clock.
+	// Zero means DefaultSkew.
+	Skew time.Duration
+	// Remembers nonces to reject replayed requests. Replays are not detected
+	// when this is nil.
+	NonceStore NonceStore
+	// Let the middleware accept GET and HEAD requests that carry a bewit
+	// query parameter instead of an Authorization header.
+	AllowBewit bool
+	// Take the host and port that the client signed from the
+	// X-Forwarded-Host, X-Forwarded-Port and X-Forwarded-Proto headers. Only
+	// enable this behind a single trusted proxy that overwrites these headers
+	// or appends to them, because the last value is used.
+	TrustForwardedHeaders bool
+	// Reject requests without a payload hash. Otherwise the body of such
+	// requests is not authenticated.
+	RequirePayloadHash bool
+	// Limit on the size of request bodies that are read to verify their
+	// payload hash. Zero means DefaultMaxBodySize.
+	MaxBodySize int64
+
+	now func() time.Time
+}
+
+// Create a verifier that finds credentials with the given lookup and allows
+// the default clock skew.
+func NewVerifier(lookup CredentialsLookup) *Verifier {
+	return &Verifier{Lookup: lookup, Skew: DefaultSkew}
+}
+
+// Find the credentials for the id. Nil credentials from the lookup are
+// treated as unknown credentials.
+func (v *Verifier) lookupCredentials(id string) (*Credentials, error) {
+	credentials, err := v.Lookup.LookupCredentials(id)
+	if err != nil {
+		return nil, err
+	}
+	if credentials == nil {
+		return nil, ErrUnknownCredentials
+	}
+	return credentials, nil
+}
+
+func (v *Verifier) skew() time.Duration {
+	if v.Skew == 0 {
+		return DefaultSkew
+	}
+	return v.Skew
+}
+
+func (v *Verifier) timestamp() time.Time {
+	if v.now != nil {
+		return v.now()
+	}
+	return time.Now()
+}
+
+// Authenticate the request from its Authorization header. If the header has a
+// payload hash then the body is read to verify it and replaced with a copy so
+// that it can still be read by the caller. Returns the credentials of the
+// client.
+func (v *Verifier) Authenticate(req *http.Request) (*Credentials, error) {
+	credentials, err := v.authenticate(req)
+	if err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// Like Authenticate, but also returns the credentials when the timestamp is
+// stale, so that the challenge can include the server time.
+func (v *Verifier) authenticate(req *http.Request) (*Credentials, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return nil, ErrMissingHeader
+	}
+
+	authorization, err := ParseAuthorization(header)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := v.lookupCredentials(authorization.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts, err := v.newArtifacts(req, authorization.TS, authorization.Nonce, authorization.Hash, authorization.Ext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	artifacts.app, artifacts.dlg = authorization.App, authorization.Dlg
+	if !hmac.Equal([]byte(hawkMAC(credentials.hash(), credentials.key, "header", artifacts)), []byte(authorization.MAC)) {
+		return nil, ErrBadMAC
+	}
+
+	if authorization.Hash == "" && v.RequirePayloadHash {
+		return nil, ErrMissingPayloadHash
+	}
+	if authorization.Hash != "" {
+		if err := v.verifyPayloadHash(credentials.hash(), req, authorization.Hash); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.checkTimestampAndNonce(authorization.ID, authorization.TS, authorization.Nonce); err != nil {
+		if err == ErrStaleTimestamp {
+			return credentials, err
+		}
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// Like newArtifacts, but with the host and port from the forwarded
+// headers if the verifier trusts them.
+func (v *Verifier) newArtifacts(req *http.Request, ts int64, nonce string, payloadHash string, ext string) (*hawkArtifacts, error) {
+	artifacts, err := newArtifacts(req, ts, nonce, payloadHash, ext)
+	if err != nil || !v.TrustForwardedHeaders {
+		return artifacts, err
+	}
+
+	u := hawkURLForRequest(req)
+	if host := forwardedHeader(req, "X-Forwarded-Host"); host != "" {
+		u.Host = host
+	}
+	if proto := forwardedHeader(req, "X-Forwarded-Proto"); proto != "" {
+		u.Scheme = strings.ToLower(proto)
+	}
+
+	if artifacts.host, err = hostForURL(u); err != nil {
+		return nil, err
+	}
+	if port := forwardedHeader(req, "X-Forwarded-Port"); port != "" {
+		artifacts.port, err = strconv.Atoi(port)
+	} else {
+		artifacts.port, err = portForURL(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// Returns the last value of a forwarded header. Proxies append to these
+// headers, so the last value is the one that the trusted proxy added. Earlier
+// values come from the client and cannot be trusted.
+func forwardedHeader(req *http.Request, name string) string {
+	values := req.Header.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	value := values[len(values)-1]
+	if i := strings.LastIndexByte(value, ','); i != -1 {
+		value = value[i+1:]
+	}
+	return strings.TrimSpace(value)
+}
+
+// Check that the timestamp is within the allowed clock skew and that the nonce
+// was not used before.
+func (v *Verifier) checkTimestampAndNonce(id string, ts int64, nonce string) error {
+	now, skew := v.timestamp(), v.skew()
+	if d := now.Sub(time.Unix(ts, 0)); d > skew || d < -skew {
+		return ErrStaleTimestamp
+	}
+
+	if v.NonceStore != nil {
+		ok, err := v.NonceStore.Add(id, ts, nonce, time.Unix(ts, 0).Add(skew).Sub(now))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrReplayedNonce
+		}
+	}
+
+	return nil
+}
+
+// Verify the payload hash against the request body and put back the body.
+// Bodies larger than the maximum body size fail with ErrBodyTooLarge.
+func (v *Verifier) verifyPayloadHash(newHash func() hash.Hash, req *http.Request, expected string) error {
+	limit := v.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(io.LimitReader(req.Body, limit+1)); err != nil {
+			return err
+		}
+		if int64(len(body)) > limit {
+			return ErrBodyTooLarge
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	hash, err := hawkContentPayloadHash(newHash, req.Header.Get("Content-Type"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(hash), []byte(expected)) {
+		return ErrBadPayloadHash
+	}
+	return nil
+}
+
+// Calculate the MAC that proves the server time in a stale timestamp
+// challenge.
+func hawkTimestampMAC(newHash func() hash.Hash, key []byte, ts int64) string {
+	mac := hmac.New(newHash, key)
+	io.WriteString(mac, "hawk.1.ts\n")
+	io.WriteString(mac, strconv.FormatInt(ts, 10)+"\n")
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Write the error response for a request that failed authentication.
+func (v *Verifier) challenge(w http.ResponseWriter, credentials *Credentials, err error) {
+	switch {
+	case errors.Is(err, ErrMissingHeader):
+		w.Header().Set("WWW-Authenticate", "Hawk")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrInvalidHeader), errors.Is(err, ErrInvalidBewit):
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+	case errors.Is(err, ErrStaleTimestamp):
+		ts := v.timestamp().Unix()
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Hawk ts="%d", tsm="%s", error="Stale timestamp"`, ts, hawkTimestampMAC(credentials.hash(), credentials.key, ts)))
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrUnknownCredentials):
+		w.Header().Set("WWW-Authenticate", `Hawk error="Unknown credentials"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrBadMAC):
+		w.Header().Set("WWW-Authenticate", `Hawk error="Bad mac"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrBadPayloadHash):
+		w.Header().Set("WWW-Authenticate", `Hawk error="Bad payload hash"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrMissingPayloadHash):
+		w.Header().Set("WWW-Authenticate", `Hawk error="Missing required payload hash"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrBodyTooLarge):
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrReplayedNonce):
+		w.Header().Set("WWW-Authenticate", `Hawk error="Invalid nonce"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, ErrExpiredBewit):
+		w.Header().Set("WWW-Authenticate", `Hawk error="Access expired"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+type credentialsContextKey struct{}
+
+// Wrap the handler so that it only receives requests that authenticate. Other
+// requests are answered with 401 and a WWW-Authenticate challenge, or 400 if
+// the Authorization header or bewit cannot be parsed. The credentials of the
+// client can be found with CredentialsFromContext.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var credentials *Credentials
+		var err error
+		if v.AllowBewit && r.Header.Get("Authorization") == "" && hasBewit(r) {
+			credentials, err = v.AuthenticateBewit(r)
+		} else {
+			credentials, err = v.authenticate(r)
+		}
+		if err != nil {
+			v.challenge(w, credentials, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), credentialsContextKey{}, credentials)))
+	})
+}
+
+// Returns the credentials of a request that was authenticated by the
+// middleware of a Verifier.
+func CredentialsFromContext(ctx context.Context) (*Credentials, bool) {
+	credentials, ok := ctx.Value(credentialsContextKey{}).(*Credentials)
+	return credentials, ok
+}
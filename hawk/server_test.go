@@ -0,0 +1,342 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testLookup = CredentialsLookupFunc(func(id string) (*Credentials, error) {
+	if id != "dh37fgj492je" {
+		return nil, ErrUnknownCredentials
+	}
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	return &credentials, nil
+})
+
+func newTestServer(verifier *Verifier) *httptest.Server {
+	return httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credentials, ok := CredentialsFromContext(r.Context())
+		if !ok {
+			http.Error(w, "No credentials in context", http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(credentials.ID() + ":" + string(body)))
+	})))
+}
+
+func doTestRequest(t *testing.T, credentials Credentials, method, url, body, signedBody string) (*http.Response, string) {
+	var request *http.Request
+	if body != "" {
+		request, _ = http.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Content-Type", "text/plain")
+	} else {
+		request, _ = http.NewRequest(method, url, nil)
+	}
+
+	var payload io.Reader
+	if signedBody != "" {
+		payload = strings.NewReader(signedBody)
+	}
+	if err := NewSigner(credentials).AuthorizeRequest(request, payload, "some-app-ext-data"); err != nil {
+		t.Fatal("AuthorizeRequest failed: ", err)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	defer response.Body.Close()
+	responseBody, _ := ioutil.ReadAll(response.Body)
+	return response, string(responseBody)
+}
+
+func Test_Verifier_GET(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, body := doTestRequest(t, credentials, "GET", server.URL+"/resource/1?b=1&a=2", "", "")
+	if response.StatusCode != http.StatusOK || body != "dh37fgj492je:" {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, body)
+	}
+}
+
+func Test_Verifier_POST(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, body := doTestRequest(t, credentials, "POST", server.URL+"/resource/1", "Thank you for flying Hawk", "Thank you for flying Hawk")
+	if response.StatusCode != http.StatusOK || body != "dh37fgj492je:Thank you for flying Hawk" {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, body)
+	}
+}
+
+func Test_Verifier_BadPayloadHash(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, _ := doTestRequest(t, credentials, "POST", server.URL+"/resource/1", "Thank you for flying Hawk", "Thank you for flying Bird")
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `Hawk error="Bad payload hash"` {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func Test_Verifier_RequirePayloadHash(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.RequirePayloadHash = true
+	server := newTestServer(verifier)
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, _ := doTestRequest(t, credentials, "POST", server.URL+"/resource/1", "Thank you for flying Hawk", "")
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `Hawk error="Missing required payload hash"` {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+
+	response, body := doTestRequest(t, credentials, "POST", server.URL+"/resource/1", "Thank you for flying Hawk", "Thank you for flying Hawk")
+	if response.StatusCode != http.StatusOK || body != "dh37fgj492je:Thank you for flying Hawk" {
+		t.Error("Unexpected response: ", response.Status, body)
+	}
+}
+
+func Test_Verifier_BadMAC(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("wrong"))
+	response, _ := doTestRequest(t, credentials, "GET", server.URL+"/resource/1", "", "")
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `Hawk error="Bad mac"` {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func Test_Verifier_UnknownCredentials(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	credentials := NewCredentials("unknown", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, _ := doTestRequest(t, credentials, "GET", server.URL+"/resource/1", "", "")
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `Hawk error="Unknown credentials"` {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func Test_Verifier_ZeroValue(t *testing.T) {
+	server := newTestServer(&Verifier{Lookup: testLookup})
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, body := doTestRequest(t, credentials, "GET", server.URL+"/resource/1?b=1&a=2", "", "")
+	if response.StatusCode != http.StatusOK || body != "dh37fgj492je:" {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, body)
+	}
+}
+
+func Test_Verifier_NilCredentials(t *testing.T) {
+	verifier := NewVerifier(CredentialsLookupFunc(func(id string) (*Credentials, error) {
+		return nil, nil
+	}))
+	server := newTestServer(verifier)
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, _ := doTestRequest(t, credentials, "GET", server.URL+"/resource/1", "", "")
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != `Hawk error="Unknown credentials"` {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+
+	authorization, _ := NewSigner(credentials).AuthorizeMessage("example.com", 8080, []byte("some message"))
+	if _, err := verifier.AuthenticateMessage("example.com", 8080, []byte("some message"), authorization); err != ErrUnknownCredentials {
+		t.Error("Expected ErrUnknownCredentials. Got ", err)
+	}
+}
+
+func Test_Verifier_MaxBodySize(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.MaxBodySize = 16
+	server := newTestServer(verifier)
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, _ := doTestRequest(t, credentials, "POST", server.URL+"/resource/1", "Thank you for flying Hawk", "Thank you for flying Hawk")
+	if response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Error("Unexpected response: ", response.Status)
+	}
+
+	response, body := doTestRequest(t, credentials, "POST", server.URL+"/resource/1", "Fly Hawk", "Fly Hawk")
+	if response.StatusCode != http.StatusOK || body != "dh37fgj492je:Fly Hawk" {
+		t.Error("Unexpected response: ", response.Status, body)
+	}
+}
+
+func Test_Verifier_MissingHeader(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/resource/1")
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized || response.Header.Get("WWW-Authenticate") != "Hawk" {
+		t.Errorf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func Test_Verifier_InvalidHeader(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/resource/1", nil)
+	request.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="soon", nonce="j4h3g2", mac="abc"`)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Unexpected response: %d", response.StatusCode)
+	}
+}
+
+func Test_Verifier_StaleTimestamp(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.now = func() time.Time { return time.Unix(1353832234, 0) }
+	server := newTestServer(verifier)
+	defer server.Close()
+
+	credentials := NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))
+	response, _ := doTestRequest(t, credentials, "GET", server.URL+"/resource/1", "", "")
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unexpected response: %d", response.StatusCode)
+	}
+
+	match := regexp.MustCompile(`^Hawk ts="(\d+)", tsm="([^"]+)", error="Stale timestamp"$`).FindStringSubmatch(response.Header.Get("WWW-Authenticate"))
+	if match == nil || match[1] != "1353832234" {
+		t.Fatal("Unexpected WWW-Authenticate: ", response.Header.Get("WWW-Authenticate"))
+	}
+	if match[2] != hawkTimestampMAC(sha256.New, []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), 1353832234) {
+		t.Error("Unexpected tsm: ", match[2])
+	}
+}
+
+func Test_Verifier_Authenticate(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.now = func() time.Time { return time.Unix(1353832240, 0) }
+
+	request := httptest.NewRequest("GET", "http://example.com:8000/resource/1?b=1&a=2", nil)
+	request.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+
+	credentials, err := verifier.Authenticate(request)
+	if err != nil || credentials.ID() != "dh37fgj492je" {
+		t.Error("Authenticate failed: ", err)
+	}
+}
+
+func Test_Verifier_SHA1(t *testing.T) {
+	lookup := CredentialsLookupFunc(func(id string) (*Credentials, error) {
+		credentials, err := NewCredentialsWithAlgorithm(id, []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), SHA1)
+		return &credentials, err
+	})
+	server := newTestServer(NewVerifier(lookup))
+	defer server.Close()
+
+	credentials, _ := NewCredentialsWithAlgorithm("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"), SHA1)
+	response, body := doTestRequest(t, credentials, "POST", server.URL+"/resource/1?b=1&a=2", "Thank you for flying Hawk", "Thank you for flying Hawk")
+	if response.StatusCode != http.StatusOK || body != "dh37fgj492je:Thank you for flying Hawk" {
+		t.Error("Unexpected response: ", response.Status, body)
+	}
+
+	response, _ = doTestRequest(t, NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn")), "GET", server.URL+"/resource/1", "", "")
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected 401 for sha256 signature. Got ", response.Status)
+	}
+}
+
+func Test_Verifier_TrustForwardedHeaders(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	verifier.now = func() time.Time { return time.Unix(1353832240, 0) }
+
+	newRequest := func(headers map[string]string) *http.Request {
+		request := httptest.NewRequest("GET", "http://backend:9000/resource/1?b=1&a=2", nil)
+		request.Header.Set("Authorization", `Hawk id="dh37fgj492je", ts="1353832234", nonce="j4h3g2", ext="some-app-ext-data", mac="6R4rV5iE+NPoym+WwjeHzjAGXUtLNIxmo1vpMofpLAE="`)
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		return request
+	}
+
+	if _, err := verifier.Authenticate(newRequest(map[string]string{"X-Forwarded-Host": "example.com:8000"})); err != ErrBadMAC {
+		t.Error("Expected ErrBadMAC when forwarded headers are not trusted. Got ", err)
+	}
+
+	verifier.TrustForwardedHeaders = true
+	for _, headers := range []map[string]string{
+		{"X-Forwarded-Host": "example.com:8000"},
+		{"X-Forwarded-Host": "spoofed.example, example.com", "X-Forwarded-Port": "8000"},
+		{"X-Forwarded-Host": "example.com:8000", "X-Forwarded-Proto": "https"},
+	} {
+		if _, err := verifier.Authenticate(newRequest(headers)); err != nil {
+			t.Error("Authenticate failed with ", headers, ": ", err)
+		}
+	}
+
+	spoofed := newRequest(map[string]string{"X-Forwarded-Host": "example.com:8000, other.example:8000"})
+	if _, err := verifier.Authenticate(spoofed); err != ErrBadMAC {
+		t.Error("Expected ErrBadMAC for a spoofed first forwarded host. Got ", err)
+	}
+	spoofed = newRequest(map[string]string{"X-Forwarded-Host": "example.com:8000"})
+	spoofed.Header.Add("X-Forwarded-Host", "other.example:8000")
+	if _, err := verifier.Authenticate(spoofed); err != ErrBadMAC {
+		t.Error("Expected ErrBadMAC for a spoofed first forwarded host header. Got ", err)
+	}
+
+	if _, err := verifier.Authenticate(newRequest(map[string]string{"X-Forwarded-Host": "example.com", "X-Forwarded-Proto": "https"})); err != ErrBadMAC {
+		t.Error("Expected ErrBadMAC for port 443. Got ", err)
+	}
+	if _, err := verifier.Authenticate(newRequest(map[string]string{"X-Forwarded-Port": "http"})); !errors.Is(err, ErrInvalidHeader) {
+		t.Error("Expected ErrInvalidHeader. Got ", err)
+	}
+}
+
+func Test_Verifier_AppAndExt(t *testing.T) {
+	verifier := NewVerifier(testLookup)
+	var authorization string
+	server := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	})))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			Signer:  NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))),
+			Options: RequestOptions{Ext: "{'key': 'value'}", App: "some-app", Dlg: "some-dlg"},
+		},
+	}
+	response, err := client.Get(server.URL + "/resource/1")
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+	if !strings.Contains(authorization, `ext="{'key': 'value'}"`) || !strings.Contains(authorization, `app="some-app", dlg="some-dlg"`) {
+		t.Error("Unexpected Authorization header: ", authorization)
+	}
+}
@@ -0,0 +1,153 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"time"
+)
+
+// A Signer signs requests, URLs and messages with Hawk credentials and
+// validates the responses to signed requests.
+type Signer struct {
+	Credentials Credentials
+	// Added to the local clock to get the server time, for clients whose
+	// clock is off.
+	Offset time.Duration
+	// Reject responses without a payload hash in their Server-Authorization
+	// header. Otherwise the body of such responses is not authenticated.
+	RequireResponseHash bool
+
+	now   func() time.Time
+	nonce func() (string, error)
+}
+
+// Create a signer for the given credentials.
+func NewSigner(credentials Credentials) *Signer {
+	return &Signer{Credentials: credentials}
+}
+
+// Returns the timestamp for a new signature. Tests can replace the clock.
+func (s *Signer) timestamp() time.Time {
+	if s.now != nil {
+		return s.now().Add(s.Offset)
+	}
+	return time.Now().Add(s.Offset)
+}
+
+// Returns the nonce for a new signature. Tests can replace the source.
+func (s *Signer) newNonce() (string, error) {
+	if s.nonce != nil {
+		return s.nonce()
+	}
+	return randomNonce()
+}
+
+// Sign the request by adding a Hawk Authorization header. The body, if not
+// nil, must contain the same bytes as the request body and is used for the
+// payload hash.
+func (s *Signer) AuthorizeRequest(req *http.Request, body io.Reader, ext string) error {
+	return s.AuthorizeRequestWithOptions(req, body, RequestOptions{Ext: ext})
+}
+
+// Like AuthorizeRequest, but with application delegation or host and port
+// overrides.
+func (s *Signer) AuthorizeRequestWithOptions(req *http.Request, body io.Reader, options RequestOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	payloadHash, err := hawkPayloadHash(s.Credentials.hash(), req, body)
+	if err != nil {
+		return err
+	}
+
+	ts := s.timestamp()
+	nonce, err := s.newNonce()
+	if err != nil {
+		return err
+	}
+
+	artifacts, err := newArtifacts(req, ts.Unix(), nonce, payloadHash, options.Ext)
+	if err != nil {
+		return err
+	}
+	artifacts.app, artifacts.dlg = options.App, options.Dlg
+	options.apply(artifacts)
+
+	authorization := &Authorization{
+		ID:    s.Credentials.id,
+		TS:    ts.Unix(),
+		Nonce: nonce,
+		Hash:  payloadHash,
+		Ext:   options.Ext,
+		MAC:   hawkMAC(s.Credentials.hash(), s.Credentials.key, "header", artifacts),
+		App:   options.App,
+		Dlg:   options.Dlg,
+	}
+
+	req.Header.Add("Authorization", authorization.String())
+
+	return nil
+}
+
+// Validate the Server-Authorization header of a response to a request that was
+// signed with AuthorizeRequest. The body is the complete response body. The
+// payload hash is checked if the server included one, and required if
+// RequireResponseHash is set.
+func (s *Signer) ValidateResponse(req *http.Request, res *http.Response, body []byte) error {
+	return s.validateResponse(req, res, body, RequestOptions{})
+}
+
+// Like ValidateResponse, for a request that was signed with host and port
+// overrides. Only the Host and Port options are used.
+func (s *Signer) ValidateResponseWithOptions(req *http.Request, res *http.Response, body []byte, options RequestOptions) error {
+	return s.validateResponse(req, res, body, options)
+}
+
+func (s *Signer) validateResponse(req *http.Request, res *http.Response, body []byte, options RequestOptions) error {
+	header := res.Header.Get("Server-Authorization")
+	if header == "" {
+		return ErrMissingHeader
+	}
+	attributes, err := parseServerAuthorization(header)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := ParseAuthorization(req.Header.Get("Authorization"))
+	if err != nil {
+		return err
+	}
+
+	artifacts, err := newArtifacts(req, authorization.TS, authorization.Nonce, attributes["hash"], attributes["ext"])
+	if err != nil {
+		return err
+	}
+	artifacts.app, artifacts.dlg = authorization.App, authorization.Dlg
+	options.apply(artifacts)
+	if !hmac.Equal([]byte(hawkMAC(s.Credentials.hash(), s.Credentials.key, "response", artifacts)), []byte(attributes["mac"])) {
+		return ErrBadMAC
+	}
+
+	if attributes["hash"] == "" {
+		if s.RequireResponseHash {
+			return ErrBadPayloadHash
+		}
+		return nil
+	}
+	hash, err := hawkContentPayloadHash(s.Credentials.hash(), res.Header.Get("Content-Type"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(hash), []byte(attributes["hash"])) {
+		return ErrBadPayloadHash
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// A Transport is an http.RoundTripper that signs every request with a Signer,
+// including a payload hash of the request body, before passing it on to the
+// underlying transport. An Authorization header already present on the
+// request is replaced.
+type Transport struct {
+	Signer *Signer
+	// The ext, app, dlg, host and port options used for every request.
+	Options RequestOptions
+	// The transport used to send the signed requests. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// Sign and send the request. The request itself is not modified. If the
+// request has a GetBody function then it is used to hash the body without
+// buffering it, otherwise the body is read into memory.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+
+	var payload io.Reader
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				req.Body.Close()
+				return nil, err
+			}
+			defer body.Close()
+			payload = body
+		} else {
+			body, err := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+			signed.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(body)), nil
+			}
+			payload = bytes.NewReader(body)
+		}
+	}
+
+	signed.Header.Del("Authorization")
+	if err := t.Signer.AuthorizeRequestWithOptions(signed, payload, t.Options); err != nil {
+		if signed.Body != nil {
+			signed.Body.Close()
+		}
+		return nil, err
+	}
+
+	return t.transport().RoundTrip(signed)
+}
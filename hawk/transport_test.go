@@ -0,0 +1,87 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/
+
+package hawk
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestClient() *http.Client {
+	return &http.Client{
+		Transport: &Transport{
+			Signer:  NewSigner(NewCredentials("dh37fgj492je", []byte("werxhqb98rpaxn39848xrunpaw3489ruxnpa98w4rxn"))),
+			Options: RequestOptions{Ext: "some-app-ext-data"},
+		},
+	}
+}
+
+func doTransportRequest(t *testing.T, request *http.Request) string {
+	response, err := newTestClient().Do(request)
+	if err != nil {
+		t.Fatal("Request failed: ", err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected response: %d %s", response.StatusCode, response.Header.Get("WWW-Authenticate"))
+	}
+	return string(body)
+}
+
+func Test_Transport_GET(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/resource/1?b=1&a=2", nil)
+	if body := doTransportRequest(t, request); body != "dh37fgj492je:" {
+		t.Error("Unexpected body: ", body)
+	}
+	if request.Header.Get("Authorization") != "" {
+		t.Error("Original request was modified")
+	}
+}
+
+func Test_Transport_POST(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	request, _ := http.NewRequest("POST", server.URL+"/resource/1", strings.NewReader("Thank you for flying Hawk"))
+	request.Header.Set("Content-Type", "text/plain")
+	if request.GetBody == nil {
+		t.Fatal("Expected a request with GetBody")
+	}
+	if body := doTransportRequest(t, request); body != "dh37fgj492je:Thank you for flying Hawk" {
+		t.Error("Unexpected body: ", body)
+	}
+}
+
+func Test_Transport_POSTWithoutGetBody(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	request, _ := http.NewRequest("POST", server.URL+"/resource/1", io.MultiReader(strings.NewReader("Thank you "), strings.NewReader("for flying Hawk")))
+	request.Header.Set("Content-Type", "text/plain")
+	if request.GetBody != nil {
+		t.Fatal("Expected a request without GetBody")
+	}
+	if body := doTransportRequest(t, request); body != "dh37fgj492je:Thank you for flying Hawk" {
+		t.Error("Unexpected body: ", body)
+	}
+}
+
+func Test_Transport_ReplacesAuthorization(t *testing.T) {
+	server := newTestServer(NewVerifier(testLookup))
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/resource/1", nil)
+	request.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	if body := doTransportRequest(t, request); body != "dh37fgj492je:" {
+		t.Error("Unexpected body: ", body)
+	}
+}